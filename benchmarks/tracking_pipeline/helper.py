@@ -1,6 +1,9 @@
 from typing import Any, Iterable
 import requests
 import json
+import logging
+import os
+import threading
 import time
 import grpc
 import asyncio
@@ -10,6 +13,7 @@ class MetricService():
         self.METRICS = dict()
         self.address = address
         self.enabled = address is not None
+        self.headers = metric_headers()
 
     def SetMetric(self, key: str, value: Any):
         self.METRICS[key] = value
@@ -30,7 +34,40 @@ class MetricService():
         if self.enabled:
             with Timer("metric-time", self):
                 data = json.dumps(self.METRICS)
-                requests.post(self.address, data, timeout=5)
+                requests.post(self.address, data, headers=self.headers, timeout=5)
+
+def metric_headers() -> dict:
+    """Returns the headers authenticating this workload and its measurements at the metric service."""
+    headers = dict()
+    token = os.environ.get("EVALUATION_TOKEN")
+    if token:
+        headers["Authorization"] = "Bearer " + token
+    source_token = os.environ.get("EVALUATION_SOURCE_TOKEN")
+    if source_token:
+        headers["X-Comb-Source-Token"] = source_token
+    return headers
+
+def sync_clock(address: str, headers: dict = None, rounds: int = 8):
+    """Exchanges timestamps with the metric service at address to estimate the clock offset of this node."""
+    for _ in range(rounds):
+        origin = time.time_ns()
+        exchange = requests.post(address + "/clock/sync", json.dumps({"origin": origin}), headers=headers, timeout=5).json()
+        exchange["destination"] = time.time_ns()
+        requests.post(address + "/clock/report", json.dumps(exchange), headers=headers, timeout=5)
+
+def start_clock_sync(address: str, interval: float = 60.0) -> threading.Thread:
+    """Synchronizes the clock with the metric service at address now and every interval seconds in the background, so the service can estimate offset and drift of this node."""
+    def run():
+        while True:
+            try:
+                sync_clock(address, metric_headers())
+            except (requests.RequestException, ValueError) as e:
+                logging.warning(f"Clock synchronization failed: {e}")
+            time.sleep(interval)
+
+    thread = threading.Thread(target=run, daemon=True)
+    thread.start()
+    return thread
 
 class Timer():
     def __init__(self, metric_name: str = None, metric_service: MetricService = None) -> None:
@@ -141,6 +141,7 @@ class Detector(detection.ObjectDetectionBase):
 async def main():
     if not ARGS["evaluation_address"] is None:
         metric_service = helper.MetricService(ARGS["evaluation_address"] + "/detection")
+        helper.start_clock_sync(ARGS["evaluation_address"])
     else:
         metric_service = helper.MetricService()
     
@@ -177,6 +177,7 @@ async def main():
             ARGS["evaluation_address"] + "/tracking")
         result_service = helper.MetricService(
             ARGS["evaluation_address"] + "/pipeline-results")
+        helper.start_clock_sync(ARGS["evaluation_address"])
     else:
         metric_service = helper.MetricService()
         result_service = helper.MetricService()
@@ -216,6 +216,7 @@ def run():
     if not ARGS["evaluation_address"] is None:
         metric_service = helper.MetricService(
             ARGS["evaluation_address"] + "/aggregation")
+        helper.start_clock_sync(ARGS["evaluation_address"])
     else:
         metric_service = helper.MetricService()
 
@@ -0,0 +1,212 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Send reports a single measurement to the endpoint with the given url, e.g.
+// "/tracking". The measurement is encoded as JSON.
+func (c *Client) Send(url string, m interface{}) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.send(c.client, http.MethodPost, c.URL()+url, body, c.sourceHeader())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return &StatusError{Method: http.MethodPost, Route: url, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// sourceHeader returns the headers describing the source of measurements.
+func (c *Client) sourceHeader() map[string]string {
+	header := make(map[string]string)
+	for k, v := range map[string]string{
+		headerSourceToken: c.opts.SourceToken,
+		headerNode:        c.opts.Source.Node,
+		headerWorkload:    c.opts.Source.Workload,
+		headerContainer:   c.opts.Source.Container,
+	} {
+		if v != "" {
+			header[k] = v
+		}
+	}
+	return header
+}
+
+// batchSample and batch are the body of /ingest-batch, see the routes package.
+type batchSample struct {
+	Session  string            `json:"session,omitempty"`
+	Url      string            `json:"url"`
+	Received time.Time         `json:"received"`
+	Header   map[string]string `json:"header,omitempty"`
+	Body     json.RawMessage   `json:"body"`
+}
+
+// Sent lets the service translate the receive times to its own clock.
+type batch struct {
+	Sent    time.Time     `json:"sent"`
+	Samples []batchSample `json:"samples"`
+}
+
+// BatchResult counts the measurements of a batch which the metric service
+// accepted and which it dropped, e.g. because their run was over.
+type BatchResult struct {
+	Accepted int `json:"accepted"`
+	Dropped  int `json:"dropped"`
+}
+
+// QueueBatches limits the measurements a Batcher keeps while the service
+// is unavailable to this many batches.
+const QueueBatches = 100
+
+// Batcher reports measurements in batches, saving a request per measurement
+// for workloads with high sample rates. Measurements keep the time they were
+// added. A Batcher is safe for concurrent use.
+type Batcher struct {
+	c       *Client
+	size    int
+	mu      sync.Mutex
+	samples []batchSample
+	dropped int
+	err     error
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewBatcher creates a batcher sending up to size measurements per batch.
+// Pending measurements are sent at least every interval, 0 only sends full
+// batches and on Flush.
+func (c *Client) NewBatcher(size int, interval time.Duration) *Batcher {
+	if size < 1 {
+		size = 1
+	}
+	b := &Batcher{c: c, size: size, stop: make(chan struct{}), done: make(chan struct{})}
+	go b.run(interval)
+	return b
+}
+
+func (b *Batcher) run(interval time.Duration) {
+	defer close(b.done)
+	if interval <= 0 {
+		<-b.stop
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			_, err := b.Flush()
+			if err != nil {
+				b.mu.Lock()
+				b.err = err
+				b.mu.Unlock()
+			}
+		}
+	}
+}
+
+// Add queues a measurement for the endpoint with the given url and sends the
+// batch once it is full. Errors of batches sent in the background are
+// returned by the next call of Add.
+func (b *Batcher) Add(url string, m interface{}) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.samples = append(b.samples, batchSample{
+		Session:  b.c.session,
+		Url:      url,
+		Received: time.Now(),
+		Header:   b.c.sourceHeader(),
+		Body:     body,
+	})
+	b.limit()
+	full := len(b.samples) >= b.size
+	err, b.err = b.err, nil
+	if err == nil && b.dropped > 0 {
+		err = fmt.Errorf("dropped %d measurements while the metric service was unavailable", b.dropped)
+		b.dropped = 0
+	}
+	b.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	if full {
+		_, err = b.Flush()
+	}
+	return err
+}
+
+// Flush sends all queued measurements. Measurements which did not reach
+// the service or which it could not process for the moment (5xx) are kept
+// for the next flush, batches it refused are dropped.
+func (b *Batcher) Flush() (BatchResult, error) {
+	b.mu.Lock()
+	samples := b.samples
+	b.samples = nil
+	b.mu.Unlock()
+
+	var res BatchResult
+	if len(samples) == 0 {
+		return res, nil
+	}
+	err := b.c.do(http.MethodPost, b.c.base+"/ingest-batch", batch{Sent: time.Now(), Samples: samples}, http.StatusOK, &res)
+	if requeue(err) {
+		b.mu.Lock()
+		b.samples = append(samples, b.samples...)
+		b.limit()
+		b.mu.Unlock()
+	}
+	return res, err
+}
+
+// requeue reports whether a batch failed without being processed and can be
+// sent again.
+func requeue(err error) bool {
+	if err == nil {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.StatusCode >= 500
+	}
+	// Requests which failed in transport, unlike undecodable answers.
+	var transport *url.Error
+	return errors.As(err, &transport)
+}
+
+// limit drops the oldest measurements beyond QueueBatches batches. The
+// caller holds b.mu.
+func (b *Batcher) limit() {
+	if over := len(b.samples) - QueueBatches*b.size; over > 0 {
+		b.dropped += over
+		b.samples = b.samples[over:]
+	}
+}
+
+// Close stops sending in the background and sends the remaining measurements.
+func (b *Batcher) Close() error {
+	close(b.stop)
+	<-b.done
+	_, err := b.Flush()
+	return err
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBatcherFlush(t *testing.T) {
+	type testCase struct {
+		status  int
+		pending int
+	}
+	tests := map[string]testCase{
+		"accepted":     {status: http.StatusOK, pending: 0},
+		"unavailable":  {status: http.StatusServiceUnavailable, pending: 2},
+		"server error": {status: http.StatusInternalServerError, pending: 2},
+		"unauthorized": {status: http.StatusUnauthorized, pending: 0},
+		"forbidden":    {status: http.StatusForbidden, pending: 0},
+		"conflict":     {status: http.StatusConflict, pending: 0},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var b batch
+				err := json.NewDecoder(r.Body).Decode(&b)
+				if err != nil || b.Sent.IsZero() {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(tc.status)
+				json.NewEncoder(w).Encode(BatchResult{Accepted: len(b.Samples)})
+			}))
+			defer srv.Close()
+
+			c, err := New(srv.URL, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			b := c.NewBatcher(10, 0)
+			defer b.Close()
+			for _, v := range []float64{1, 2} {
+				err = b.Add("/test", map[string]float64{"value": v})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, err = b.Flush()
+			if (tc.status == http.StatusOK) != (err == nil) {
+				t.Fatalf("expected: %v, got: %v", tc.status, err)
+			}
+			if len(b.samples) != tc.pending {
+				t.Fatalf("expected: %v, got: %v", tc.pending, len(b.samples))
+			}
+		})
+	}
+}
+
+func TestBatcherUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	c, err := New(srv.URL, Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := c.NewBatcher(2, 0)
+	defer b.Close()
+
+	// The queue keeps QueueBatches batches, older measurements are dropped.
+	total := QueueBatches*2 + 3
+	for i := 0; i < total; i++ {
+		b.mu.Lock()
+		b.samples = append(b.samples, batchSample{Url: "/test", Body: json.RawMessage(`{}`)})
+		b.limit()
+		b.mu.Unlock()
+	}
+	_, err = b.Flush()
+	if err == nil {
+		t.Fatal("expected an error of the unreachable service")
+	}
+	if len(b.samples) != QueueBatches*2 || b.dropped != 3 {
+		t.Fatalf("expected: %v, got: %v", []int{QueueBatches * 2, 3}, []int{len(b.samples), b.dropped})
+	}
+
+	err = b.Add("/test", map[string]float64{"value": 1})
+	if err == nil {
+		t.Fatal("expected dropped measurements to be reported")
+	}
+}
@@ -0,0 +1,309 @@
+// Package client reports measurements to the metric service and controls
+// its benchmark sessions and runs.
+//
+// Requests are retried if the service could not be reached or answered that
+// it is unavailable, i.e. whenever the request was certainly not processed.
+// Other unexpected answers are returned as *StatusError.
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Default timeouts of requests. Ending a run aggregates all its measurements
+// and waits for relays, so it is generous. Other requests fail fast, so
+// workloads do not stall while the metric service does.
+const (
+	DefaultTimeout       = 10 * time.Second
+	DefaultEndRunTimeout = 10 * time.Minute
+)
+
+// Headers describing the source of measurements, see the routes package.
+const (
+	headerSourceToken = "X-Comb-Source-Token"
+	headerNode        = "X-Comb-Node"
+	headerWorkload    = "X-Comb-Workload"
+	headerContainer   = "X-Comb-Container"
+)
+
+// Options configures a client. Token is sent as bearer token, SourceToken
+// and Source describe the workload sending measurements. Timeout limits each
+// attempt of a request, EndRunTimeout those of EndRun. Failed requests are
+// retried up to Retries times, waiting Backoff before the first retry and
+// twice as long before every further one.
+type Options struct {
+	Token         string
+	TLS           *tls.Config
+	Timeout       time.Duration
+	EndRunTimeout time.Duration
+	Retries       int
+	Backoff       time.Duration
+	SourceToken   string
+	Source        Source
+}
+
+// Client issues requests against the metric service. Clients returned by
+// StartBenchmark and ResumeBenchmark are scoped to their session.
+type Client struct {
+	base    string
+	session string
+	opts    Options
+	client  *http.Client
+	// endRun is client, but limited by EndRunTimeout.
+	endRun *http.Client
+}
+
+// New creates a client of the metric service at address, e.g.
+// "http://metrics:8000".
+func New(address string, opts Options) (*Client, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("expected http(s)://<host>[:<port>], got %s", address)
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.EndRunTimeout == 0 {
+		opts.EndRunTimeout = DefaultEndRunTimeout
+	}
+	if opts.Backoff == 0 {
+		opts.Backoff = time.Second
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = opts.TLS
+	return &Client{
+		base:   strings.TrimSuffix(address, "/"),
+		opts:   opts,
+		client: &http.Client{Transport: transport, Timeout: opts.Timeout},
+		endRun: &http.Client{Transport: transport, Timeout: opts.EndRunTimeout},
+	}, nil
+}
+
+// StatusError is returned if the metric service answered with an unexpected
+// status code.
+type StatusError struct {
+	Method     string
+	Route      string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: unexpected status %d %s", e.Method, e.Route, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// IsStatus reports whether err is a *StatusError with the given code, e.g.
+// http.StatusConflict if no run was started.
+func IsStatus(err error, code int) bool {
+	var status *StatusError
+	return errors.As(err, &status) && status.StatusCode == code
+}
+
+// SessionInfo describes a benchmark session of the metric service.
+type SessionInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	Root string `json:"root"`
+	Run  int    `json:"run"`
+	Runs []Run  `json:"runs"`
+}
+
+// Completed returns the matchings of all runs of the session which can be
+// evaluated, so they do not have to be repeated.
+func (s SessionInfo) Completed() []map[string]string {
+	var completed []map[string]string
+	for _, run := range s.Runs {
+		if run.Valid() {
+			completed = append(completed, run.Matching)
+		}
+	}
+	return completed
+}
+
+// RunRequest starts a run. Sources maps the source tokens handed to the
+// workloads to their description, Manifest is recorded in the manifest of
+// the run.
+type RunRequest struct {
+	Matching map[string]string `json:"matching"`
+	Sources  map[string]Source `json:"sources,omitempty"`
+	Manifest interface{}       `json:"manifest,omitempty"`
+}
+
+// URL returns the base address of the client, including the session scope.
+// Workloads post their measurements below it.
+func (c *Client) URL() string {
+	if c.session == "" {
+		return c.base
+	}
+	return fmt.Sprintf("%s/sessions/%s", c.base, c.session)
+}
+
+// Session returns the ID of the session the client is scoped to.
+func (c *Client) Session() string {
+	return c.session
+}
+
+// StartBenchmark opens a new benchmark session and returns a client scoped
+// to it. The name is available in the result folder layout.
+func (c *Client) StartBenchmark(name string) (*Client, SessionInfo, error) {
+	return c.openSession("/start-benchmark", map[string]string{"name": name})
+}
+
+// ResumeBenchmark continues the session with the given ID or the benchmark
+// in the folder with that name and returns a client scoped to the session.
+func (c *Client) ResumeBenchmark(id string) (*Client, SessionInfo, error) {
+	return c.openSession("/resume-benchmark", map[string]string{"id": id})
+}
+
+func (c *Client) openSession(route string, req interface{}) (*Client, SessionInfo, error) {
+	var info SessionInfo
+	err := c.do(http.MethodPost, c.base+route, req, http.StatusOK, &info)
+	if err != nil {
+		return nil, info, err
+	}
+	if info.ID == "" {
+		return nil, info, errors.New("metric service did not return a session")
+	}
+
+	scoped := *c
+	scoped.session = info.ID
+	return &scoped, info, nil
+}
+
+// EndBenchmark ends the session of the client.
+func (c *Client) EndBenchmark() error {
+	return c.do(http.MethodPost, c.URL()+"/end-benchmark", nil, http.StatusOK, nil)
+}
+
+// Sessions describes all open sessions.
+func (c *Client) Sessions() ([]SessionInfo, error) {
+	var infos []SessionInfo
+	err := c.do(http.MethodGet, c.base+"/sessions", nil, http.StatusOK, &infos)
+	return infos, err
+}
+
+// GetSession describes the session with the given ID.
+func (c *Client) GetSession(id string) (SessionInfo, error) {
+	var info SessionInfo
+	err := c.do(http.MethodGet, fmt.Sprintf("%s/sessions/%s", c.base, url.PathEscape(id)), nil, http.StatusOK, &info)
+	return info, err
+}
+
+// StartRun starts the next run of the session.
+func (c *Client) StartRun(req RunRequest) error {
+	return c.do(http.MethodPost, c.URL()+"/start-run", req, http.StatusOK, nil)
+}
+
+// EndRun ends the current run and returns its results.
+func (c *Client) EndRun() (Results, error) {
+	var res Results
+	err := c.request(c.endRun, http.MethodPost, c.URL()+"/end-run", nil, http.StatusOK, &res)
+	return res, err
+}
+
+// AbortRun stops the current run without evaluating it.
+func (c *Client) AbortRun(reason string) error {
+	return c.do(http.MethodPost, c.URL()+"/abort-run", map[string]string{"reason": reason}, http.StatusOK, nil)
+}
+
+// Snapshot aggregates the metrics of the current run so far.
+func (c *Client) Snapshot() (Snapshot, error) {
+	var snapshot Snapshot
+	err := c.do(http.MethodPost, c.URL()+"/snapshot", nil, http.StatusOK, &snapshot)
+	return snapshot, err
+}
+
+// do sends req encoded as JSON to address and decodes the response into out
+// if the service answered with the expected status.
+func (c *Client) do(method string, address string, req interface{}, expected int, out interface{}) error {
+	return c.request(c.client, method, address, req, expected, out)
+}
+
+// request is do with the given http client.
+func (c *Client) request(client *http.Client, method string, address string, req interface{}, expected int, out interface{}) error {
+	var body []byte
+	if req != nil {
+		var err error
+		body, err = json.Marshal(req)
+		if err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.send(client, method, address, body, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expected {
+		return &StatusError{Method: method, Route: strings.TrimPrefix(address, c.base), StatusCode: resp.StatusCode}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send issues the request and retries it while it was not processed. The
+// caller has to close the response body.
+func (c *Client) send(client *http.Client, method string, address string, body []byte, header map[string]string) (*http.Response, error) {
+	backoff := c.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequest(method, address, reader)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if c.opts.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.opts.Token)
+		}
+
+		resp, err := client.Do(req)
+		if attempt >= c.opts.Retries || !retry(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// retry reports whether a request was certainly not processed, because the
+// service could not be reached or answered that it is unavailable.
+func retry(resp *http.Response, err error) bool {
+	if err != nil {
+		var op *net.OpError
+		return errors.As(err, &op) && op.Op == "dial"
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	type testCase struct {
+		statuses []int
+		attempts int
+		status   int
+	}
+	tests := map[string]testCase{
+		"unavailable": {
+			statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			attempts: 3,
+		},
+		"exhausted": {
+			statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK},
+			attempts: 3,
+			status:   http.StatusServiceUnavailable,
+		},
+		"processed": {
+			statuses: []int{http.StatusInternalServerError, http.StatusOK},
+			attempts: 1,
+			status:   http.StatusInternalServerError,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			attempts := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				if r.Header.Get("Authorization") != "Bearer secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(tc.statuses[attempts])
+				attempts++
+			}))
+			defer srv.Close()
+
+			c, err := New(srv.URL, Options{Token: "secret", Retries: 2, Backoff: time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = c.AbortRun("test")
+			if tc.status == 0 && err != nil {
+				t.Fatal(err)
+			}
+			if tc.status != 0 && !IsStatus(err, tc.status) {
+				t.Fatalf("expected: %v, got: %v", tc.status, err)
+			}
+			if attempts != tc.attempts {
+				t.Fatalf("expected: %v, got: %v", tc.attempts, attempts)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		if r.URL.Path == "/end-run" {
+			w.Write([]byte("{}"))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL, Options{Timeout: 50 * time.Millisecond, EndRunTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Send("/test", map[string]float64{"value": 1})
+	if err == nil {
+		t.Fatal("expected: timeout, got: nil")
+	}
+	_, err = c.EndRun()
+	if err != nil {
+		t.Fatalf("expected: nil, got: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package client
+
+import "time"
+
+// The types below mirror the JSON of the metric service, so workloads and
+// the orchestrator do not depend on its packages.
+
+// Source describes the workload a measurement was sent by.
+type Source struct {
+	Node      string `json:"node,omitempty"`
+	Workload  string `json:"workload,omitempty"`
+	Container string `json:"container,omitempty"`
+}
+
+// Status of a run and its endpoints after checking their completeness.
+const (
+	StatusOK     = "OK"
+	StatusWarn   = "WARN"
+	StatusFailed = "FAILED"
+)
+
+// Run describes a run of a session.
+type Run struct {
+	Name       string            `json:"name"`
+	Index      int               `json:"index"`
+	Matching   map[string]string `json:"matching,omitempty"`
+	Complete   bool              `json:"complete"`
+	Incomplete bool              `json:"incomplete,omitempty"`
+	Aborted    bool              `json:"aborted,omitempty"`
+	Status     string            `json:"status,omitempty"`
+}
+
+// Valid reports whether the run ended regularly with complete data, so its
+// results can be evaluated.
+func (r Run) Valid() bool {
+	return r.Complete && !r.Incomplete && !r.Aborted && r.Status != StatusFailed
+}
+
+// EndpointStatus describes the completeness of the measurements of an endpoint.
+type EndpointStatus struct {
+	Status   string   `json:"status"`
+	Samples  int      `json:"samples"`
+	MaxGapMs float64  `json:"maxGapMs"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// Results are the metrics of a run per endpoint.
+type Results struct {
+	Matching  map[string]string             `json:"matching"`
+	Sources   []Source                      `json:"sources,omitempty"`
+	Status    string                        `json:"status,omitempty"`
+	Endpoints map[string]EndpointStatus     `json:"endpoints,omitempty"`
+	Results   map[string]map[string]float64 `json:"results"`
+	Units     map[string]map[string]string  `json:"units,omitempty"`
+}
+
+// Snapshot holds the metrics of a run aggregated while it continued.
+type Snapshot struct {
+	Time      time.Time                     `json:"time"`
+	Elapsed   float64                       `json:"elapsedSeconds"`
+	Run       int                           `json:"run"`
+	Matching  map[string]string             `json:"matching"`
+	Endpoints map[string]EndpointStatus     `json:"endpoints,omitempty"`
+	Results   map[string]map[string]float64 `json:"results"`
+}
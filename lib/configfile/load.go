@@ -0,0 +1,334 @@
+// Package configfile loads YAML configuration files with includes, profiles,
+// overlays and environment variables, and locates settings in them.
+package configfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Keys of a configuration file which are resolved while loading it.
+const (
+	// IncludeKey lists files (relative to the including file) the
+	// configuration is merged onto.
+	IncludeKey = "Include"
+	// ProfilesKey maps profile names to overlays selected with --profile.
+	ProfilesKey = "Profiles"
+)
+
+// Document is a configuration resolved by Load.
+type Document struct {
+	// Settings is the merged configuration.
+	Settings yaml.MapSlice
+	// Files lists every file the configuration was read from.
+	Files []string
+
+	// path is the configuration file, merged are the settings before ${ENV} references were replaced,
+	// sources the documents merged into them in this order.
+	path    string
+	merged  yaml.MapSlice
+	sources []source
+}
+
+// source is a document merged into the configuration. Profiles are part of
+// the file defining them, prefix is their path in it.
+type source struct {
+	file   string
+	lines  []yamlLine
+	doc    yaml.MapSlice
+	prefix string
+}
+
+// Load reads the YAML configuration file path and resolves it to a single
+// document: the included files, the file itself, the selected profile and
+// the overlay files are merged in this order, then ${ENV} references are
+// replaced. Mappings are merged by key, lists of mappings by their Name,
+// other values are replaced.
+func Load(path string, profile string, overlays []string) (*Document, error) {
+	d := &Document{path: path}
+	doc, err := d.loadFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	main := d.sources[len(d.sources)-1]
+
+	profiles, doc := remove(doc, ProfilesKey)
+	if profile != "" {
+		overlay, ok := lookup(asMap(profiles), profile)
+		if !ok {
+			return nil, fmt.Errorf("%s: profile %s not found", path, profile)
+		}
+		doc = merge(doc, overlay).(yaml.MapSlice)
+		d.sources = append(d.sources, source{
+			file:   path,
+			lines:  main.lines,
+			doc:    asMap(overlay),
+			prefix: ProfilesKey + "." + profile,
+		})
+	}
+
+	for _, o := range overlays {
+		overlay, err := d.loadFile(o, nil)
+		if err != nil {
+			return nil, err
+		}
+		doc = merge(doc, overlay).(yaml.MapSlice)
+	}
+
+	out, err := interpolate(doc)
+	if err != nil {
+		return nil, err
+	}
+	d.Settings = out.(yaml.MapSlice)
+	d.merged = doc
+	return d, nil
+}
+
+// Redact replaces the values of the given keys, e.g. before printing the
+// configuration.
+func Redact(v interface{}, keys ...string) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		out := make(yaml.MapSlice, len(v))
+		for i, item := range v {
+			out[i] = yaml.MapItem{Key: item.Key, Value: Redact(item.Value, keys...)}
+			for _, k := range keys {
+				if strings.EqualFold(fmt.Sprint(item.Key), k) {
+					out[i].Value = redactValue(item.Value)
+				}
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = Redact(item, keys...)
+		}
+		return out
+	}
+	return v
+}
+
+func redactValue(v interface{}) interface{} {
+	if list, ok := v.([]interface{}); ok {
+		out := make([]interface{}, len(list))
+		for i := range out {
+			out[i] = "<redacted>"
+		}
+		return out
+	}
+	if v == nil || v == "" {
+		return v
+	}
+	return "<redacted>"
+}
+
+// loadFile reads path and merges it onto its includes. Seen holds the files
+// currently being included to detect cycles.
+func (d *Document) loadFile(path string, seen []string) (yaml.MapSlice, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range seen {
+		if s == abs {
+			return nil, fmt.Errorf("%s: include cycle", path)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.MapSlice
+	err = yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	include, doc := remove(doc, IncludeKey)
+	var files []string
+	switch include := include.(type) {
+	case nil:
+	case string:
+		files = []string{include}
+	case []interface{}:
+		for _, f := range include {
+			files = append(files, fmt.Sprint(f))
+		}
+	default:
+		return nil, fmt.Errorf("%s: %s must be a file or a list of files", path, IncludeKey)
+	}
+
+	var base yaml.MapSlice
+	for _, f := range files {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(filepath.Dir(path), f)
+		}
+		included, err := d.loadFile(f, append(seen, abs))
+		if err != nil {
+			return nil, err
+		}
+		base = merge(base, included).(yaml.MapSlice)
+	}
+
+	if !contains(d.Files, path) {
+		d.Files = append(d.Files, path)
+	}
+	d.sources = append(d.sources, source{file: path, lines: yamlLines(data), doc: doc})
+	return merge(base, doc).(yaml.MapSlice), nil
+}
+
+// merge merges overlay onto base.
+func merge(base interface{}, overlay interface{}) interface{} {
+	switch o := overlay.(type) {
+	case yaml.MapSlice:
+		b, ok := base.(yaml.MapSlice)
+		if !ok {
+			b = yaml.MapSlice{}
+		}
+		out := append(yaml.MapSlice{}, b...)
+		for _, item := range o {
+			i := index(out, fmt.Sprint(item.Key))
+			if i < 0 {
+				out = append(out, item)
+				continue
+			}
+			out[i].Value = merge(out[i].Value, item.Value)
+		}
+		return out
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok || !named(o) || !named(b) {
+			return o
+		}
+		out := append([]interface{}{}, b...)
+		for _, item := range o {
+			name, _ := lookup(item.(yaml.MapSlice), "Name")
+			found := false
+			for i, existing := range out {
+				if n, _ := lookup(existing.(yaml.MapSlice), "Name"); n == name {
+					out[i] = merge(existing, item)
+					found = true
+					break
+				}
+			}
+			if !found {
+				out = append(out, item)
+			}
+		}
+		return out
+	}
+	return overlay
+}
+
+// named reports whether list only holds mappings with a Name.
+func named(list []interface{}) bool {
+	for _, item := range list {
+		m, ok := item.(yaml.MapSlice)
+		if !ok {
+			return false
+		}
+		if _, ok := lookup(m, "Name"); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// envRef matches ${VAR} and ${VAR:-default}, $${ escapes a literal ${.
+var envRef = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// interpolate replaces ${ENV} references in all string values.
+func interpolate(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		out := make(yaml.MapSlice, len(v))
+		for i, item := range v {
+			value, err := interpolate(item.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", item.Key, err)
+			}
+			out[i] = yaml.MapItem{Key: item.Key, Value: value}
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			value, err := interpolate(item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			out[i] = value
+		}
+		return out, nil
+	case string:
+		var missing []string
+		out := envRef.ReplaceAllStringFunc(v, func(ref string) string {
+			if strings.HasPrefix(ref, "$$") {
+				return ref[1:]
+			}
+			m := envRef.FindStringSubmatch(ref)
+			if value, ok := os.LookupEnv(m[1]); ok {
+				return value
+			}
+			if m[2] != "" {
+				return m[3]
+			}
+			missing = append(missing, m[1])
+			return ref
+		})
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("environment variable %s not set", strings.Join(missing, ", "))
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// index returns the position of key in m, keys are compared case-insensitive.
+func index(m yaml.MapSlice, key string) int {
+	for i, item := range m {
+		if strings.EqualFold(fmt.Sprint(item.Key), key) {
+			return i
+		}
+	}
+	return -1
+}
+
+func lookup(m yaml.MapSlice, key string) (interface{}, bool) {
+	i := index(m, key)
+	if i < 0 {
+		return nil, false
+	}
+	return m[i].Value, true
+}
+
+// remove returns the value of key and m without it.
+func remove(m yaml.MapSlice, key string) (interface{}, yaml.MapSlice) {
+	i := index(m, key)
+	if i < 0 {
+		return nil, m
+	}
+	value := m[i].Value
+	return value, append(append(yaml.MapSlice{}, m[:i]...), m[i+1:]...)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func asMap(v interface{}) yaml.MapSlice {
+	m, _ := v.(yaml.MapSlice)
+	return m
+}
@@ -0,0 +1,182 @@
+package configfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	base := `RootFolder: results
+Auth:
+  IngestTokens: [a]
+Endpoints:
+  - Name: tracking
+    Url: /tracking
+    Fields: [frame-number, processing-time]
+  - Name: detection
+    Url: /detection
+`
+	type testCase struct {
+		files    map[string]string
+		profile  string
+		overlays []string
+		env      map[string]string
+		out      string
+		err      string
+	}
+	tests := map[string]testCase{
+		"include": {
+			files: map[string]string{
+				"shared/base.yaml": base,
+				"config.yaml": `Include: shared/base.yaml
+rootfolder: out
+Endpoints:
+  - Name: detection
+    Url: /detect
+  - Name: aggregation
+`,
+			},
+			out: `RootFolder: out
+Auth:
+  IngestTokens:
+  - a
+Endpoints:
+- Name: tracking
+  Url: /tracking
+  Fields:
+  - frame-number
+  - processing-time
+- Name: detection
+  Url: /detect
+- Name: aggregation
+`,
+		},
+		"profile and overlays": {
+			files: map[string]string{
+				"config.yaml": base + `Profiles:
+  testbed:
+    Endpoints:
+      - Name: tracking
+        Fields: [frame-number]
+    Port: 9000
+`,
+				"local.yaml": `Port: 9001
+Auth:
+  IngestTokens: [b, c]
+`,
+			},
+			profile:  "testbed",
+			overlays: []string{"local.yaml"},
+			out: `RootFolder: results
+Auth:
+  IngestTokens:
+  - b
+  - c
+Endpoints:
+- Name: tracking
+  Url: /tracking
+  Fields:
+  - frame-number
+- Name: detection
+  Url: /detection
+Port: 9001
+`,
+		},
+		"environment": {
+			files: map[string]string{
+				"config.yaml": `RootFolder: ${COMB_TEST_ROOT}/results
+Host: ${COMB_TEST_HOST:-localhost}
+DateFormat: $${literal}
+`,
+			},
+			env: map[string]string{"COMB_TEST_ROOT": "/data"},
+			out: `RootFolder: /data/results
+Host: localhost
+DateFormat: ${literal}
+`,
+		},
+		"missing variable": {
+			files: map[string]string{"config.yaml": "TLS:\n  KeyFile: ${COMB_TEST_MISSING}\n"},
+			err:   "TLS: KeyFile: environment variable COMB_TEST_MISSING not set",
+		},
+		"unknown profile": {
+			files:   map[string]string{"config.yaml": base},
+			profile: "testbed",
+			err:     "profile testbed not found",
+		},
+		"include cycle": {
+			files: map[string]string{
+				"config.yaml": "Include: [other.yaml]\n",
+				"other.yaml":  "Include: config.yaml\n",
+			},
+			err: "include cycle",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := writeFiles(t, tc.files)
+			for k, v := range tc.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			var overlays []string
+			for _, o := range tc.overlays {
+				overlays = append(overlays, filepath.Join(dir, o))
+			}
+
+			doc, err := Load(filepath.Join(dir, "config.yaml"), tc.profile, overlays)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected: %v, got: %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := yaml.Marshal(doc.Settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tc.out {
+				t.Fatalf("expected: %v, got: %v", tc.out, string(out))
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	doc := yaml.MapSlice{
+		{Key: "Auth", Value: yaml.MapSlice{{Key: "ControlTokens", Value: []interface{}{"secret"}}}},
+		{Key: "Port", Value: 8000},
+	}
+	out, err := yaml.Marshal(Redact(doc, "controltokens"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Auth:\n  ControlTokens:\n  - <redacted>\nPort: 8000\n"
+	if string(out) != expected {
+		t.Fatalf("expected: %v, got: %v", expected, string(out))
+	}
+}
@@ -0,0 +1,186 @@
+package configfile
+
+import (
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Locate returns the file and line of the setting path, e.g.
+// "Endpoints[1].Metrics.processing-time". The setting is looked up in the
+// documents merged into the configuration, the last one defining the most of
+// it wins. Settings which are not part of any (e.g. defaults) return the
+// line of their closest parent, or line 0 of the configuration file.
+func (d *Document) Locate(path string) (string, int) {
+	elems := splitPath(path)
+	file, line, best := d.path, 0, 0
+	for i := len(d.sources) - 1; i >= 0; i-- {
+		src := d.sources[i]
+		own, n := translate(d.merged, src.doc, elems)
+		if n <= best {
+			continue
+		}
+		if src.prefix != "" {
+			own = append(splitPath(src.prefix), own...)
+		}
+		file, line, best = src.file, locate(src.lines, strings.Join(own, ".")), n
+	}
+	return file, line
+}
+
+// translate follows elems through the merged settings and the document own,
+// which was merged into them. Items of lists merged by their Name are found
+// by it. It returns the path in own and the number of elements found.
+func translate(merged interface{}, own interface{}, elems []string) ([]string, int) {
+	var out []string
+	for n, elem := range elems {
+		switch o := own.(type) {
+		case yaml.MapSlice:
+			v, ok := lookup(o, elem)
+			if !ok {
+				return out, n
+			}
+			merged, _ = lookup(asMap(merged), elem)
+			own = v
+			out = append(out, elem)
+		case []interface{}:
+			i, err := strconv.Atoi(elem)
+			if err != nil {
+				return out, n
+			}
+			list, _ := merged.([]interface{})
+			j := i
+			if named(o) && i < len(list) {
+				name, _ := lookup(asMap(list[i]), "Name")
+				j = -1
+				for k, item := range o {
+					if v, _ := lookup(item.(yaml.MapSlice), "Name"); v == name {
+						j = k
+					}
+				}
+			}
+			if j < 0 || j >= len(o) {
+				return out, n
+			}
+			merged = nil
+			if i < len(list) {
+				merged = list[i]
+			}
+			own = o[j]
+			out = append(out, strconv.Itoa(j))
+		default:
+			return out, n
+		}
+	}
+	return out, len(elems)
+}
+
+type yamlLine struct {
+	number int
+	indent int
+	// Items start with "- ", col is the column of their content.
+	item bool
+	col  int
+	key  string
+}
+
+func yamlLines(data []byte) []yamlLine {
+	var lines []yamlLine
+	for i, text := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+
+		l := yamlLine{number: i + 1, indent: len(text) - len(trimmed)}
+		l.col = l.indent
+		content := trimmed
+		if content == "-" || strings.HasPrefix(content, "- ") {
+			l.item = true
+			rest := strings.TrimLeft(strings.TrimPrefix(content, "-"), " ")
+			l.col += len(content) - len(rest)
+			content = rest
+		}
+		l.key = yamlKey(content)
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// yamlKey returns the mapping key at the start of content, if any.
+func yamlKey(content string) string {
+	if strings.HasPrefix(content, "[") || strings.HasPrefix(content, "{") {
+		return ""
+	}
+	n := strings.Index(content, ": ")
+	if strings.HasSuffix(content, ":") && (n < 0 || n == len(content)-1) {
+		n = len(content) - 1
+	}
+	if n < 0 {
+		return ""
+	}
+	return strings.Trim(content[:n], `"' `)
+}
+
+// locate follows path through the document and returns the line of the
+// deepest setting found, 0 if not even the first one was found.
+func locate(lines []yamlLine, path string) int {
+	lo, hi := 0, len(lines)
+	found := 0
+	for _, elem := range splitPath(path) {
+		if lo >= hi {
+			break
+		}
+
+		match := -1
+		if index, err := strconv.Atoi(elem); err == nil {
+			// The n-th item of a sequence, its content starts on the item line.
+			n := 0
+			for i := lo; i < hi && match < 0; i++ {
+				if lines[i].item && lines[i].indent == lines[lo].indent {
+					if n == index {
+						match = i
+					}
+					n++
+				}
+			}
+			if match < 0 {
+				break
+			}
+			lo, hi = match, blockEnd(lines, match, hi, lines[match].indent, false)
+		} else {
+			col := lines[lo].col
+			for i := lo; i < hi && match < 0; i++ {
+				if lines[i].col == col && strings.EqualFold(lines[i].key, elem) {
+					match = i
+				}
+			}
+			if match < 0 {
+				break
+			}
+			lo, hi = match+1, blockEnd(lines, match, hi, col, true)
+		}
+		found = lines[match].number
+	}
+	return found
+}
+
+// blockEnd returns the end of the block following line i, i.e. all lines
+// indented deeper than indent. Values may be sequences at the same indentation.
+func blockEnd(lines []yamlLine, i int, hi int, indent int, value bool) int {
+	for j := i + 1; j < hi; j++ {
+		if lines[j].indent > indent || (value && lines[j].indent == indent && lines[j].item) {
+			continue
+		}
+		return j
+	}
+	return hi
+}
+
+// splitPath splits "Endpoints[1].Config.MotScript" into its elements.
+func splitPath(path string) []string {
+	path = strings.ReplaceAll(path, "[", ".")
+	path = strings.ReplaceAll(path, "]", "")
+	return strings.Split(path, ".")
+}
@@ -0,0 +1,114 @@
+package configfile
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testYAML = `RootFolder: results
+Layout:
+  Run: "{{.Run"
+Endpoints:
+  - Name: MOT
+    Module: MOT
+    Config:
+      MotScript: run.py # TrackEval
+    Metrics:
+      - HOTA: []
+  - Name: tracking
+    Fields: ["frame-number", "processing-time"]
+    Metrics:
+    - processing-time: [MIN, MAX]
+    - latency: [P99]
+    Completeness:
+      Severity: ERROR
+`
+
+func TestLocate(t *testing.T) {
+	type testCase struct {
+		path string
+		line int
+	}
+	tests := map[string]testCase{
+		"top level":             {path: "RootFolder", line: 1},
+		"nested":                {path: "Layout.Run", line: 3},
+		"first key of item":     {path: "Endpoints[1].Name", line: 11},
+		"module setting":        {path: "Endpoints[0].Config.MotScript", line: 8},
+		"list of maps":          {path: "Endpoints[0].Metrics.HOTA", line: 10},
+		"unindented sequence":   {path: "Endpoints[1].Metrics.latency", line: 15},
+		"after sequence":        {path: "Endpoints[1].Completeness.Severity", line: 17},
+		"flow sequence":         {path: "Endpoints[1].Fields[1]", line: 12},
+		"case insensitive":      {path: "endpoints[1].completeness", line: 16},
+		"missing setting":       {path: "Endpoints[0].Config.SeqInfo", line: 7},
+		"missing in other item": {path: "Endpoints[1].Config", line: 11},
+		"unknown":               {path: "Port", line: 0},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			line := locate(yamlLines([]byte(testYAML)), tc.path)
+			if line != tc.line {
+				t.Fatalf("expected: %v, got: %v", tc.line, line)
+			}
+		})
+	}
+}
+
+func TestDocumentLocate(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"shared/base.yaml": `RootFolder: results
+Endpoints:
+  - Name: tracking
+    Url: /tracking
+  - Name: detection
+    Url: /detection
+`,
+		"config.yaml": `Include: shared/base.yaml
+Endpoints:
+  - Name: detection
+    Fields: [value]
+Profiles:
+  testbed:
+    Port: 9000
+`,
+		"local.yaml": `Endpoints:
+  - Name: aggregation
+    Url: /aggregation
+`,
+	})
+	path := filepath.Join(dir, "config.yaml")
+	doc, err := Load(path, "testbed", []string{filepath.Join(dir, "local.yaml")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type testCase struct {
+		path string
+		file string
+		line int
+	}
+	tests := map[string]testCase{
+		"included":         {path: "Endpoints[0].Url", file: "shared/base.yaml", line: 4},
+		"included setting": {path: "Endpoints[1].Url", file: "shared/base.yaml", line: 6},
+		"merged item":      {path: "Endpoints[1].Fields", file: "config.yaml", line: 4},
+		"profile":          {path: "Port", file: "config.yaml", line: 7},
+		"overlay":          {path: "Endpoints[2].Url", file: "local.yaml", line: 3},
+		"missing setting":  {path: "Endpoints[2].Metrics", file: "local.yaml", line: 2},
+		"unknown":          {path: "Host", file: "config.yaml", line: 0},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			file, line := doc.Locate(tc.path)
+			if file != filepath.Join(dir, tc.file) || line != tc.line {
+				t.Fatalf("expected: %v, got: %v", []interface{}{tc.file, tc.line}, []interface{}{file, line})
+			}
+		})
+	}
+
+	expected := []string{filepath.Join(dir, "shared/base.yaml"), path, filepath.Join(dir, "local.yaml")}
+	if !reflect.DeepEqual(doc.Files, expected) {
+		t.Fatalf("expected: %v, got: %v", expected, doc.Files)
+	}
+}
@@ -0,0 +1,164 @@
+package clock
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Exchange is a single NTP-style time exchange between a node and the metric
+// service. Origin and Destination are taken from the node clock, Receive and
+// Transmit from the clock of the metric service. All values are Unix
+// timestamps in nanoseconds.
+type Exchange struct {
+	Origin      int64 `json:"origin"`
+	Receive     int64 `json:"receive"`
+	Transmit    int64 `json:"transmit"`
+	Destination int64 `json:"destination"`
+}
+
+// Offset of the node clock relative to the metric service, positive values
+// mean the node clock is behind.
+func (e Exchange) Offset() time.Duration {
+	return time.Duration(((e.Receive - e.Origin) + (e.Transmit - e.Destination)) / 2)
+}
+
+// Delay is the network round trip time without the processing time of the metric service.
+func (e Exchange) Delay() time.Duration {
+	return time.Duration((e.Destination - e.Origin) - (e.Transmit - e.Receive))
+}
+
+// Estimate describes the clock of a node. The offset at time t is
+// Offset + Drift * (t - Reference).
+type Estimate struct {
+	Offset    time.Duration
+	Drift     float64
+	Error     time.Duration
+	Reference time.Time
+	Samples   int
+}
+
+// At returns the estimated offset of the node clock at time t.
+func (e Estimate) At(t time.Time) time.Duration {
+	return e.Offset + time.Duration(e.Drift*float64(t.Sub(e.Reference)))
+}
+
+// Report is the representation of an Estimate written to the run results.
+type Report struct {
+	OffsetMs  float64   `json:"offset_ms"`
+	DriftPPM  float64   `json:"drift_ppm"`
+	ErrorMs   float64   `json:"error_ms"`
+	Reference time.Time `json:"reference"`
+	Samples   int       `json:"samples"`
+}
+
+func (e Estimate) Report() Report {
+	return Report{
+		OffsetMs:  float64(e.Offset) / float64(time.Millisecond),
+		DriftPPM:  e.Drift * 1e6,
+		ErrorMs:   float64(e.Error) / float64(time.Millisecond),
+		Reference: e.Reference,
+		Samples:   e.Samples,
+	}
+}
+
+// Estimator collects time exchanges per node and estimates offset and drift of their clocks.
+type Estimator struct {
+	mu        sync.Mutex
+	exchanges map[string][]Exchange
+}
+
+func NewEstimator() *Estimator {
+	return &Estimator{exchanges: make(map[string][]Exchange)}
+}
+
+func (e *Estimator) Add(node string, ex Exchange) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.exchanges[node] = append(e.exchanges[node], ex)
+}
+
+// Reset drops all exchanges, e.g. at the start of a new run.
+func (e *Estimator) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.exchanges = make(map[string][]Exchange)
+}
+
+// Estimate returns the current estimate for node, false if the node never synchronized.
+func (e *Estimator) Estimate(node string) (Estimate, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	exchanges, ok := e.exchanges[node]
+	if !ok || len(exchanges) == 0 {
+		return Estimate{}, false
+	}
+	return estimate(exchanges), true
+}
+
+// Reports returns the estimates of all nodes.
+func (e *Estimator) Reports() map[string]Report {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make(map[string]Report, len(e.exchanges))
+	for node, exchanges := range e.exchanges {
+		out[node] = estimate(exchanges).Report()
+	}
+	return out
+}
+
+// estimate fits a line through the offsets of the exchanges with the lowest
+// delay, as these are least affected by asymmetric network paths.
+func estimate(exchanges []Exchange) Estimate {
+	sorted := make([]Exchange, len(exchanges))
+	copy(sorted, exchanges)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Delay() < sorted[j].Delay() })
+	best := sorted[:(len(sorted)+1)/2]
+	minDelay := best[0].Delay()
+
+	ref := best[0].Receive
+	for _, ex := range best {
+		if ex.Receive > ref {
+			ref = ex.Receive
+		}
+	}
+
+	// Least squares fit of offset over time relative to the latest exchange.
+	n := float64(len(best))
+	var sx, sy, sxx, sxy float64
+	for _, ex := range best {
+		x := float64(ex.Receive - ref)
+		y := float64(ex.Offset())
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	drift := 0.0
+	offset := sy / n
+	if d := n*sxx - sx*sx; d > 0 {
+		drift = (n*sxy - sx*sy) / d
+		offset = (sy - drift*sx) / n
+	}
+
+	var residuals float64
+	for _, ex := range best {
+		r := float64(ex.Offset()) - (offset + drift*float64(ex.Receive-ref))
+		residuals += r * r
+	}
+	spread := time.Duration(math.Sqrt(residuals / n))
+
+	errBound := minDelay / 2
+	if spread > errBound {
+		errBound = spread
+	}
+
+	return Estimate{
+		Offset:    time.Duration(offset),
+		Drift:     drift,
+		Error:     errBound,
+		Reference: time.Unix(0, ref),
+		Samples:   len(exchanges),
+	}
+}
@@ -0,0 +1,66 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+// exchange simulates a time exchange at server time t with a node whose clock
+// is behind by offset and a one way network delay of delay.
+func exchange(t time.Time, offset time.Duration, delay time.Duration) Exchange {
+	return Exchange{
+		Origin:      t.Add(-offset).UnixNano(),
+		Receive:     t.Add(delay).UnixNano(),
+		Transmit:    t.Add(delay + time.Millisecond).UnixNano(),
+		Destination: t.Add(2*delay + time.Millisecond - offset).UnixNano(),
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	type testCase struct {
+		offset time.Duration
+		drift  float64
+		delays []time.Duration
+	}
+	tests := map[string]testCase{
+		"single": {
+			offset: 50 * time.Millisecond,
+			delays: []time.Duration{2 * time.Millisecond},
+		},
+		"negative": {
+			offset: -120 * time.Millisecond,
+			delays: []time.Duration{2 * time.Millisecond, 3 * time.Millisecond, 2 * time.Millisecond},
+		},
+		"drift": {
+			offset: 10 * time.Millisecond,
+			drift:  50e-6,
+			delays: []time.Duration{2 * time.Millisecond, 2 * time.Millisecond, 2 * time.Millisecond, 2 * time.Millisecond},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			start := time.Unix(1600000000, 0)
+			e := NewEstimator()
+			var last time.Time
+			for i, d := range tc.delays {
+				last = start.Add(time.Duration(i) * time.Minute)
+				offset := tc.offset + time.Duration(tc.drift*float64(last.Sub(start)))
+				e.Add("node", exchange(last, offset, d))
+			}
+
+			est, ok := e.Estimate("node")
+			if !ok {
+				t.Fatal("expected estimate")
+			}
+
+			expected := tc.offset + time.Duration(tc.drift*float64(last.Sub(start)))
+			if diff := est.At(last) - expected; diff > 100*time.Microsecond || diff < -100*time.Microsecond {
+				t.Fatalf("expected: %v, got: %v", expected, est.At(last))
+			}
+			if diff := est.Drift - tc.drift; diff > 1e-6 || diff < -1e-6 {
+				t.Fatalf("expected: %v, got: %v", tc.drift, est.Drift)
+			}
+		})
+	}
+}
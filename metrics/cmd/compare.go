@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/sbaeurle/comb/metrics/compare"
+)
+
+var compareCmd = &cobra.Command{
+	Use:   "compare <baseline folder> <candidate folder>",
+	Short: "Compare two benchmarks and detect regressions.",
+	Args:  cobra.ExactArgs(2),
+	RunE:  compareBenchmarks,
+}
+
+var (
+	alpha      float64
+	outputJSON bool
+)
+
+func init() {
+	compareCmd.Flags().Float64Var(&alpha, "alpha", 0, "significance level (default Compare.Alpha or 0.05)")
+	compareCmd.Flags().BoolVar(&outputJSON, "json", false, "print the comparison as JSON")
+}
+
+func compareBenchmarks(cmd *cobra.Command, args []string) error {
+	if alpha != 0 {
+		cfg.Compare.Alpha = alpha
+	}
+
+	c, err := compare.Compare(args[0], args[1], cfg)
+	if err != nil {
+		return err
+	}
+	for _, m := range c.BaselineOnly {
+		log.Warnf("Matching %s only found in the baseline", m)
+	}
+	for _, m := range c.CandidateOnly {
+		log.Warnf("Matching %s only found in the candidate", m)
+	}
+
+	if outputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(c)
+	} else {
+		err = printComparison(c)
+	}
+	if err != nil {
+		return err
+	}
+
+	if regressions := c.Regressions(); len(regressions) > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d regressions detected", len(regressions))
+	}
+	return nil
+}
+
+func printComparison(c compare.Comparison) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "MATCHING\tMETRIC\tBASELINE\tCANDIDATE\tCHANGE\tTEST\tP\tEFFECT\tDIRECTION\t")
+	for _, r := range c.Results {
+		change, test, p, effect := "-", "-", "-", "-"
+		if r.Change != nil {
+			change = fmt.Sprintf("%+.1f%%", *r.Change*100)
+		}
+		if r.Test != nil {
+			test = r.Test.Name
+			p = fmt.Sprintf("%.3g", r.Test.P)
+			effect = fmt.Sprintf("%+.2f", r.Test.Effect)
+		}
+		direction := r.Direction
+		if r.Regression {
+			direction += " (regression)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.4g\t%.4g\t%s\t%s\t%s\t%s\t%s\t\n", r.Matching, r.Metric, r.Baseline, r.Candidate, change, test, p, effect, direction)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/sbaeurle/comb/lib/configfile"
+	"gopkg.in/yaml.v2"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Print the effective configuration after includes, profile, overlays and environment variables.",
+	Args:  cobra.NoArgs,
+	RunE:  printConfig,
+}
+
+func printConfig(cmd *cobra.Command, args []string) error {
+	doc, err := configfile.Load(cfgFile, profile, overlays)
+	if err != nil {
+		return err
+	}
+	tmp, err := yaml.Marshal(configfile.Redact(doc.Settings, "IngestTokens", "ControlTokens", "Token"))
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(tmp))
+	return nil
+}
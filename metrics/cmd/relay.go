@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/sbaeurle/comb/metrics/relay"
+	"github.com/sbaeurle/comb/metrics/routes"
+	"github.com/sbaeurle/comb/metrics/validation"
+)
+
+var relayCmd = &cobra.Command{
+	Use:   "relay",
+	Short: "Relay measurements to an upstream metric service.",
+	Long: `Accept measurements on the endpoint routes near the workloads, store them
+locally and forward them in batches to the upstream metric service configured
+under Relay. Forwarding resumes after disconnects and restarts.`,
+	Args: cobra.NoArgs,
+	RunE: runRelay,
+}
+
+func runRelay(cmd *cobra.Command, args []string) error {
+	if problems := validation.ValidateRelay(cfg); problems != nil {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("invalid configuration %s:\n%w", viper.ConfigFileUsed(), problems)
+	}
+
+	rl, err := relay.New(log, cfg.Relay)
+	if err != nil {
+		return err
+	}
+
+	r := mux.NewRouter()
+	ingest := r.NewRoute().Subrouter()
+	ingest.Use(routes.TokenAuth(log, cfg.Auth.IngestTokens))
+	rl.RegisterRoutes(ingest, cfg.Endpoints)
+	go rl.Run(make(chan struct{}))
+
+	tlsCfg, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:      fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Handler:   r,
+		TLSConfig: tlsCfg,
+	}
+
+	if tlsCfg != nil {
+		log.Infof("Relaying HTTPS on %s to %s", server.Addr, cfg.Relay.Upstream)
+		log.Fatal(server.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile))
+	} else {
+		log.Infof("Relaying HTTP on %s to %s", server.Addr, cfg.Relay.Upstream)
+		log.Fatal(server.ListenAndServe())
+	}
+
+	return nil
+}
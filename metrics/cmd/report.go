@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/sbaeurle/comb/metrics/report"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report <benchmark folder>...",
+	Short: "Generate the HTML report of finished benchmarks.",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  generateReport,
+}
+
+func generateReport(cmd *cobra.Command, args []string) error {
+	for _, path := range args {
+		out, err := report.Generate(path, cfg)
+		if err != nil {
+			return err
+		}
+		log.Infof("Wrote benchmark report %s", out)
+	}
+	return nil
+}
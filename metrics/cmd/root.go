@@ -1,22 +1,31 @@
 package cmd
 
 import (
+	"bytes"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/sbaeurle/comb/lib/configfile"
 	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/version"
 	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "evaluation",
-	Short: "Lightweight metric system to collect benchmark data.",
+	Use:     "evaluation",
+	Short:   "Lightweight metric system to collect benchmark data.",
+	Version: version.String(),
 }
 
 var (
 	cfgFile     string
+	profile     string
+	overlays    []string
 	development bool
 	log         config.Logger
 	cfg         config.Config
+	cfgDoc      *configfile.Document
 )
 
 func Execute() error {
@@ -27,13 +36,23 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(summaryCmd)
+	rootCmd.AddCommand(compareCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(relayCmd)
 	// Add configuration options
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "config.yaml", "config file")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "profile of the config file to apply")
+	rootCmd.PersistentFlags().StringArrayVar(&overlays, "overlay", nil, "config file merged onto the configuration (repeatable)")
 	rootCmd.PersistentFlags().BoolVar(&development, "development", false, "development mode")
 	rootCmd.PersistentFlags().Int("buffer-size", 10, "channel size")
-	rootCmd.PersistentFlags().Bool("plot", false, "enable result plotting")
+	rootCmd.PersistentFlags().Bool("plot", false, "generate an HTML report at the end of a benchmark")
+	rootCmd.PersistentFlags().String("host", "", "listen address (default all interfaces)")
 	rootCmd.PersistentFlags().Int("port", 8000, "http port")
 	viper.BindPFlag("BufferSize", rootCmd.PersistentFlags().Lookup("buffer-size"))
+	viper.BindPFlag("Host", rootCmd.PersistentFlags().Lookup("host"))
 	viper.BindPFlag("Port", rootCmd.PersistentFlags().Lookup("port"))
 	viper.BindPFlag("GeneratePlots", rootCmd.PersistentFlags().Lookup("plot"))
 }
@@ -43,8 +62,9 @@ func initConfig() {
 	viper.AutomaticEnv()
 	// Check for configuration errors.
 	// Exit application if errors are present.
-	err := viper.ReadInConfig()
+	doc, err := readConfig()
 	cobra.CheckErr(err)
+	cfgDoc = doc
 
 	err = viper.Unmarshal(&cfg)
 	cobra.CheckErr(err)
@@ -60,3 +80,17 @@ func initConfig() {
 	defer logger.Sync()
 	log = logger.Sugar()
 }
+
+// readConfig loads the config file with its includes, profile and overlays.
+func readConfig() (*configfile.Document, error) {
+	doc, err := configfile.Load(cfgFile, profile, overlays)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := yaml.Marshal(doc.Settings)
+	if err != nil {
+		return nil, err
+	}
+	viper.SetConfigType("yaml")
+	return doc, viper.ReadConfig(bytes.NewReader(tmp))
+}
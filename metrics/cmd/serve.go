@@ -1,11 +1,23 @@
 package cmd
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/gorilla/mux"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/sbaeurle/comb/metrics/config"
 	"github.com/sbaeurle/comb/metrics/routes"
 )
 
@@ -16,24 +28,187 @@ var serveCmd = &cobra.Command{
 }
 
 func serve(cmd *cobra.Command, args []string) error {
+	problems := validateConfig(cfg, cfgDoc)
+	if invalid := problems.Errors(); invalid != nil {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("invalid configuration %s:\n%w", viper.ConfigFileUsed(), invalid)
+	}
+	for _, p := range problems {
+		log.Warn(p.String())
+	}
+
 	r := mux.NewRouter()
 
-	modz, err := routes.RegisterRoutes(r, log, cfg)
+	// Control and ingestion routes are guarded by separate tokens.
+	control := r.NewRoute().Subrouter()
+	control.Use(routes.TokenAuth(log, cfg.Auth.ControlTokens))
+	ingest := r.NewRoute().Subrouter()
+	ingest.Use(routes.TokenAuth(log, cfg.Auth.IngestTokens))
+
+	cs, err := routes.NewControlService(log, cfg)
+	if err != nil {
+		return err
+	}
+	cs.SetLoader(loadConfig)
+	cs.RegisterControlRoutes(control)
+	routes.NewQueryService(log, cfg).RegisterQueryRoutes(control)
+	events := r.NewRoute().Subrouter()
+	events.Use(routes.StreamTokenAuth(log, cfg.Auth.ControlTokens))
+	routes.NewDashboard(log, cs, time.Second).RegisterDashboardRoutes(r, events)
+
+	err = routes.RegisterRoutes(ingest, log, cfg, cs)
+	if err != nil {
+		return err
+	}
+	routes.NewClockService(log, cs).RegisterClockRoutes(ingest)
+
+	err = watchConfig(cs, cfgDoc.Files)
 	if err != nil {
 		return err
 	}
 
-	control, err := routes.NewControlService(log, cfg, modz)
+	tlsCfg, err := newTLSConfig(cfg.TLS)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:      fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Handler:   r,
+		TLSConfig: tlsCfg,
+	}
+
+	if tlsCfg != nil {
+		log.Infof("Serving HTTPS on %s", server.Addr)
+		log.Fatal(server.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile))
+	} else {
+		log.Infof("Serving HTTP on %s", server.Addr)
+		log.Fatal(server.ListenAndServe())
+	}
+
+	return nil
+}
+
+// loadConfig reads the configuration files again. Invalid configurations
+// return their problems.
+func loadConfig() (config.Config, error) {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	var c config.Config
+	doc, err := readConfig()
+	if err != nil {
+		return c, err
+	}
+	// Includes and overlays may have changed, the watcher follows them.
+	select {
+	case <-configFiles:
+	default:
+	}
+	configFiles <- doc.Files
+
+	err = viper.Unmarshal(&c)
 	if err != nil {
-		return nil
+		return c, err
+	}
+	if invalid := validateConfig(c, doc).Errors(); invalid != nil {
+		return c, invalid
 	}
+	return c, nil
+}
 
-	r.HandleFunc("/start-benchmark", control.StartBenchmark).Methods("POST")
-	r.HandleFunc("/end-benchmark", control.EndBenchmark).Methods("POST")
-	r.HandleFunc("/start-run", control.StartRun).Methods("POST")
-	r.HandleFunc("/end-run", control.EndRun).Methods("POST")
+var (
+	loadMu sync.Mutex
+	// configFiles passes the files of the last loaded configuration to the
+	// watcher.
+	configFiles = make(chan []string, 1)
+)
 
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", cfg.Port), r))
+// watchConfig reloads the configuration when one of its files changes or on
+// SIGHUP. The folders of the files are watched, so files replaced by editors
+// are noticed as well. Sessions apply it with their next run.
+func watchConfig(cs *routes.ControlService, files []string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
 
+	reload := func() {
+		c, err := loadConfig()
+		if err == nil {
+			_, err = cs.Reload(c)
+		}
+		if err != nil {
+			log.Errorf("Configuration %s not reloaded:\n%v", viper.ConfigFileUsed(), err)
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		watched := watchFiles(watcher, files)
+		for {
+			select {
+			case files := <-configFiles:
+				watched = watchFiles(watcher, files)
+			case event := <-watcher.Events:
+				if event.Op&(fsnotify.Write|fsnotify.Create) != 0 && watched[filepath.Clean(event.Name)] {
+					reload()
+				}
+			case err := <-watcher.Errors:
+				log.Errorf("Watching the configuration failed: %v", err)
+			case <-hup:
+				reload()
+			}
+		}
+	}()
 	return nil
 }
+
+// watchFiles adds the folders of files to the watcher and returns the files
+// by their absolute path.
+func watchFiles(watcher *fsnotify.Watcher, files []string) map[string]bool {
+	watched := make(map[string]bool)
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			log.Warnf("Not watching %s: %v", f, err)
+			continue
+		}
+		err = watcher.Add(filepath.Dir(abs))
+		if err != nil {
+			log.Warnf("Not watching %s: %v", f, err)
+			continue
+		}
+		watched[abs] = true
+	}
+	return watched
+}
+
+func newTLSConfig(c config.TLSConfig) (*tls.Config, error) {
+	if c.CertFile == "" && c.KeyFile == "" {
+		if c.ClientCAFile != "" {
+			return nil, errors.New("client certificate verification requires TLS.CertFile and TLS.KeyFile")
+		}
+		return nil, nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("TLS requires both TLS.CertFile and TLS.KeyFile")
+	}
+
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.ClientCAFile)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsCfg, nil
+}
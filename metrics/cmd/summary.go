@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/sbaeurle/comb/metrics/summary"
+)
+
+var summaryCmd = &cobra.Command{
+	Use:   "summary <benchmark folder>...",
+	Short: "Write the leaderboard of finished benchmarks.",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  writeSummary,
+}
+
+var (
+	sortBy     string
+	descending bool
+	printCSV   bool
+)
+
+func init() {
+	summaryCmd.Flags().StringVar(&sortBy, "sort", "", "metric to sort by, e.g. tracking/processing-time-AVG (default Summary.SortBy)")
+	summaryCmd.Flags().BoolVar(&descending, "descending", false, "sort in descending order")
+	summaryCmd.Flags().BoolVar(&printCSV, "print", false, "additionally print the leaderboard as CSV")
+}
+
+func writeSummary(cmd *cobra.Command, args []string) error {
+	if sortBy == "" {
+		sortBy = cfg.Summary.SortBy
+		descending = descending || cfg.Summary.Descending
+	}
+
+	for _, path := range args {
+		s, err := summary.Load(path)
+		if err != nil {
+			return err
+		}
+		if sortBy != "" {
+			err = s.Sort(sortBy, descending)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = summary.Write(path, s)
+		if err != nil {
+			return err
+		}
+		log.Infof("Wrote leaderboard of %d runs to %s", len(s.Rows), filepath.Join(path, summary.CSVFile))
+
+		if printCSV {
+			err = s.WriteCSV(os.Stdout)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
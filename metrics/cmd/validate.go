@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/sbaeurle/comb/lib/configfile"
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/validation"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the configuration for problems.",
+	Args:  cobra.NoArgs,
+	RunE:  validate,
+}
+
+func validate(cmd *cobra.Command, args []string) error {
+	problems := validateConfig(cfg, cfgDoc)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if invalid := problems.Errors(); len(invalid) > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("found %d problems in %s", len(invalid), viper.ConfigFileUsed())
+	}
+	fmt.Printf("%s is valid\n", viper.ConfigFileUsed())
+	return nil
+}
+
+// validateConfig checks the configuration c and locates the problems in the
+// files of doc it was loaded from.
+func validateConfig(c config.Config, doc *configfile.Document) config.Problems {
+	problems := validation.Validate(c)
+	if len(problems) == 0 {
+		return nil
+	}
+	problems.Locate(doc)
+	return problems
+}
@@ -0,0 +1,234 @@
+// Package compare pairs the runs of two benchmarks by their matching and
+// tests every metric for significant changes.
+package compare
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/results"
+)
+
+// DefaultAlpha is the significance level used if none is configured.
+const DefaultAlpha = 0.05
+
+// Directions of a change.
+const (
+	Increase  = "increase"
+	Decrease  = "decrease"
+	Unchanged = "unchanged"
+)
+
+// Comparison is the outcome of comparing a candidate benchmark against a baseline.
+type Comparison struct {
+	Results []Result `json:"results"`
+	// Matchings only found in one of the benchmarks.
+	BaselineOnly  []string `json:"baselineOnly,omitempty"`
+	CandidateOnly []string `json:"candidateOnly,omitempty"`
+}
+
+// Result compares a metric of a matching. Metrics aggregated per run are
+// named "<endpoint>/<metric>" and compared by their mean over repetitions of
+// the matching with Welch's t-test. Raw samples of a field are named
+// "<endpoint>/<field>" and compared by their median with the Mann–Whitney U
+// test. Change is relative to the baseline and omitted if the baseline is 0.
+type Result struct {
+	Matching   string   `json:"matching"`
+	Metric     string   `json:"metric"`
+	Baseline   float64  `json:"baseline"`
+	Candidate  float64  `json:"candidate"`
+	Samples    [2]int   `json:"samples"`
+	Change     *float64 `json:"change,omitempty"`
+	Test       *Test    `json:"test,omitempty"`
+	Direction  string   `json:"direction"`
+	Regression bool     `json:"regression"`
+}
+
+// Regressions returns all results exceeding their regression threshold.
+func (c Comparison) Regressions() []Result {
+	var out []Result
+	for _, r := range c.Results {
+		if r.Regression {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// group holds the runs of a benchmark sharing a matching.
+type group struct {
+	metrics map[string][]float64
+	samples map[string][]float64
+}
+
+// Compare pairs the complete runs of both benchmark folders by matching and
+// compares every metric found in both.
+func Compare(baseline, candidate string, cfg config.Config) (Comparison, error) {
+	var c Comparison
+	base, err := load(baseline, cfg)
+	if err != nil {
+		return c, err
+	}
+	cand, err := load(candidate, cfg)
+	if err != nil {
+		return c, err
+	}
+
+	alpha := cfg.Compare.Alpha
+	if alpha == 0 {
+		alpha = DefaultAlpha
+	}
+
+	for _, matching := range matchings(base) {
+		b := base[matching]
+		a, ok := cand[matching]
+		if !ok {
+			c.BaselineOnly = append(c.BaselineOnly, matching)
+			continue
+		}
+
+		for _, metric := range metrics(b.metrics) {
+			if v, ok := a.metrics[metric]; ok {
+				r := newResult(matching, metric, b.metrics[metric], v, mean, Welch)
+				c.Results = append(c.Results, classify(r, alpha, cfg.Compare.Regressions))
+			}
+		}
+		for _, field := range metrics(b.samples) {
+			if v, ok := a.samples[field]; ok {
+				r := newResult(matching, field, b.samples[field], v, median, MannWhitney)
+				c.Results = append(c.Results, classify(r, alpha, cfg.Compare.Regressions))
+			}
+		}
+	}
+
+	for _, matching := range matchings(cand) {
+		if _, ok := base[matching]; !ok {
+			c.CandidateOnly = append(c.CandidateOnly, matching)
+		}
+	}
+	if c.Results == nil {
+		c.Results = []Result{}
+	}
+	return c, nil
+}
+
+func newResult(matching, metric string, baseline, candidate []float64, center func([]float64) float64, test func(a, b []float64) (Test, bool)) Result {
+	r := Result{
+		Matching:  matching,
+		Metric:    metric,
+		Baseline:  center(baseline),
+		Candidate: center(candidate),
+		Samples:   [2]int{len(baseline), len(candidate)},
+	}
+	if r.Baseline != 0 {
+		change := (r.Candidate - r.Baseline) / math.Abs(r.Baseline)
+		r.Change = &change
+	}
+	if t, ok := test(baseline, candidate); ok {
+		r.Test = &t
+	}
+	return r
+}
+
+// classify sets the direction of the change and whether it is a regression.
+// Without a significance test, e.g. for single runs, the threshold alone decides.
+func classify(r Result, alpha float64, rules []config.RegressionConfig) Result {
+	significant := r.Test == nil || r.Test.P < alpha
+	switch {
+	case !significant || r.Candidate == r.Baseline:
+		r.Direction = Unchanged
+	case r.Candidate > r.Baseline:
+		r.Direction = Increase
+	default:
+		r.Direction = Decrease
+	}
+
+	for _, rule := range rules {
+		if rule.Metric != r.Metric || r.Direction == Unchanged {
+			continue
+		}
+		worse := r.Direction == Increase
+		if rule.HigherIsBetter {
+			worse = r.Direction == Decrease
+		}
+		exceeded := r.Change == nil || math.Abs(*r.Change) > rule.Threshold
+		r.Regression = r.Regression || (worse && exceeded)
+	}
+	return r
+}
+
+// load groups the complete runs of the benchmark folder path by matching.
+func load(path string, cfg config.Config) (map[string]*group, error) {
+	runs, err := results.ListRuns(path)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*group)
+	for _, run := range runs {
+		if !run.Valid() {
+			continue
+		}
+		res, err := results.Read(run.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		key := Matching(res.Matching)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{metrics: make(map[string][]float64), samples: make(map[string][]float64)}
+			groups[key] = g
+		}
+
+		for endpoint, metrics := range res.Results {
+			for metric, v := range metrics {
+				name := fmt.Sprintf("%s/%s", endpoint, metric)
+				g.metrics[name] = append(g.metrics[name], v)
+			}
+		}
+
+		for _, endpoint := range results.RunConfig(run.Path, cfg).Endpoints {
+			_, samples, err := results.ReadSamples(run.Path, endpoint)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", run.Path, err)
+			}
+			for field, values := range samples {
+				name := fmt.Sprintf("%s/%s", endpoint.Name, field)
+				g.samples[name] = append(g.samples[name], values...)
+			}
+		}
+	}
+	return groups, nil
+}
+
+// Matching formats a matching as sorted "<workload>=<assignment>" pairs.
+func Matching(m map[string]string) string {
+	parts := make([]string, 0, len(m))
+	for workload, assignment := range m {
+		parts = append(parts, fmt.Sprintf("%s=%s", workload, assignment))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func matchings(m map[string]*group) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func metrics(m map[string][]float64) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
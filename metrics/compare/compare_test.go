@@ -0,0 +1,68 @@
+package compare
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/results"
+)
+
+func writeBenchmark(t *testing.T, values ...float64) string {
+	root := t.TempDir()
+	for i, v := range values {
+		path := filepath.Join(root, fmt.Sprintf("run%03d", i+1))
+		err := os.Mkdir(path, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = results.Write(path, results.Results{
+			Matching: map[string]string{"Tracking": "APU-10.0.0.3-cpu"},
+			Results:  map[string]map[string]float64{"tracking": {"processing-time-AVG": v}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestCompare(t *testing.T) {
+	type testCase struct {
+		candidate  []float64
+		rule       config.RegressionConfig
+		direction  string
+		regression bool
+	}
+	rule := config.RegressionConfig{Metric: "tracking/processing-time-AVG", Threshold: 0.1}
+	tests := map[string]testCase{
+		"slower":      {candidate: []float64{20, 21, 22}, rule: rule, direction: Increase, regression: true},
+		"faster":      {candidate: []float64{5, 6, 7}, rule: rule, direction: Decrease, regression: false},
+		"noise":       {candidate: []float64{9, 11, 13}, rule: rule, direction: Unchanged, regression: false},
+		"below":       {candidate: []float64{20, 21, 22}, rule: config.RegressionConfig{Metric: rule.Metric, Threshold: 2}, direction: Increase, regression: false},
+		"higher":      {candidate: []float64{20, 21, 22}, rule: config.RegressionConfig{Metric: rule.Metric, Threshold: 0.1, HigherIsBetter: true}, direction: Increase, regression: false},
+		"other":       {candidate: []float64{20, 21, 22}, rule: config.RegressionConfig{Metric: "tracking/processing-time-MAX"}, direction: Increase, regression: false},
+		"single-slow": {candidate: []float64{20}, rule: rule, direction: Increase, regression: true},
+	}
+
+	baseline := writeBenchmark(t, 10, 11, 12)
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.Config{Compare: config.CompareConfig{Regressions: []config.RegressionConfig{tc.rule}}}
+			c, err := Compare(baseline, writeBenchmark(t, tc.candidate...), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(c.Results) != 1 {
+				t.Fatalf("expected: %v, got: %v", 1, len(c.Results))
+			}
+
+			r := c.Results[0]
+			if r.Direction != tc.direction || r.Regression != tc.regression {
+				t.Fatalf("expected: %v/%v, got: %v/%v", tc.direction, tc.regression, r.Direction, r.Regression)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package compare
+
+import (
+	"math"
+	"sort"
+)
+
+// Test is the outcome of a two-sample significance test. Effect is the
+// rank-biserial correlation for the Mann–Whitney U test and Cohen's d for
+// Welch's t-test, positive if the candidate tends to be larger.
+type Test struct {
+	Name      string  `json:"name"`
+	Statistic float64 `json:"statistic"`
+	P         float64 `json:"p"`
+	Effect    float64 `json:"effect"`
+}
+
+// MannWhitney compares two independent samples without assuming a
+// distribution. The p-value uses the normal approximation with tie and
+// continuity correction, which is accurate for samples of about 20 values
+// or more.
+func MannWhitney(a, b []float64) (Test, bool) {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if len(a) == 0 || len(b) == 0 {
+		return Test{}, false
+	}
+
+	type value struct {
+		v         float64
+		candidate bool
+	}
+	all := make([]value, 0, len(a)+len(b))
+	for _, v := range a {
+		all = append(all, value{v: v})
+	}
+	for _, v := range b {
+		all = append(all, value{v: v, candidate: true})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// Assign average ranks to ties and collect the tie correction term.
+	var ranks, ties float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].candidate {
+				ranks += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	u := ranks - n2*(n2+1)/2
+	expected := n1 * n2 / 2
+	n := n1 + n2
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+
+	p := 1.0
+	if variance > 0 {
+		z := (math.Abs(u-expected) - 0.5) / math.Sqrt(variance)
+		if z < 0 {
+			z = 0
+		}
+		p = math.Erfc(z / math.Sqrt2)
+	}
+	return Test{Name: "mann-whitney", Statistic: u, P: p, Effect: 2*u/(n1*n2) - 1}, true
+}
+
+// Welch compares the means of two samples without assuming equal variances.
+func Welch(a, b []float64) (Test, bool) {
+	if len(a) < 2 || len(b) < 2 {
+		return Test{}, false
+	}
+	m1, v1 := meanVariance(a)
+	m2, v2 := meanVariance(b)
+	n1, n2 := float64(len(a)), float64(len(b))
+
+	// Without variance any difference of the means is significant. Statistic
+	// and effect size are undefined and left 0.
+	se := v1/n1 + v2/n2
+	if se == 0 {
+		if m1 == m2 {
+			return Test{Name: "welch", P: 1}, true
+		}
+		return Test{Name: "welch", P: 0}, true
+	}
+
+	t := (m2 - m1) / math.Sqrt(se)
+	df := se * se / ((v1/n1)*(v1/n1)/(n1-1) + (v2/n2)*(v2/n2)/(n2-1))
+	p := incompleteBeta(df/2, 0.5, df/(df+t*t))
+	return Test{Name: "welch", Statistic: t, P: p, Effect: (m2 - m1) / math.Sqrt((v1+v2)/2)}, true
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// meanVariance returns the mean and the sample variance of at least two values.
+func meanVariance(values []float64) (float64, float64) {
+	m := mean(values)
+	var sq float64
+	for _, v := range values {
+		sq += (v - m) * (v - m)
+	}
+	return m, sq / float64(len(values)-1)
+}
+
+func median(values []float64) float64 {
+	tmp := append([]float64(nil), values...)
+	sort.Float64s(tmp)
+	n := len(tmp)
+	if n%2 == 1 {
+		return tmp[n/2]
+	}
+	return (tmp[n/2-1] + tmp[n/2]) / 2
+}
+
+// incompleteBeta is the regularized incomplete beta function I_x(a, b).
+func incompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	lga, _ := math.Lgamma(a)
+	lgb, _ := math.Lgamma(b)
+	lgab, _ := math.Lgamma(a + b)
+	front := math.Exp(lgab - lga - lgb + a*math.Log(x) + b*math.Log(1-x))
+
+	// The continued fraction converges quickly for x < (a+1)/(a+b+2).
+	if x < (a+1)/(a+b+2) {
+		return front * betaFraction(a, b, x) / a
+	}
+	return 1 - front*betaFraction(b, a, 1-x)/b
+}
+
+// betaFraction evaluates the continued fraction of the incomplete beta
+// function with the modified Lentz method.
+func betaFraction(a, b, x float64) float64 {
+	const (
+		iterations = 200
+		epsilon    = 1e-14
+		tiny       = 1e-300
+	)
+
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1.0; m <= iterations; m++ {
+		for _, num := range []float64{
+			m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m)),
+			-(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1)),
+		} {
+			d = 1 + num*d
+			if math.Abs(d) < tiny {
+				d = tiny
+			}
+			c = 1 + num/c
+			if math.Abs(c) < tiny {
+				c = tiny
+			}
+			d = 1 / d
+			h *= d * c
+		}
+		if math.Abs(d*c-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,69 @@
+package compare
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMannWhitney(t *testing.T) {
+	type testCase struct {
+		a, b   []float64
+		u      float64
+		p      float64
+		effect float64
+	}
+	tests := map[string]testCase{
+		"separated": {a: []float64{1, 2, 3}, b: []float64{4, 5, 6}, u: 9, p: 0.0809, effect: 1},
+		"reversed":  {a: []float64{4, 5, 6}, b: []float64{1, 2, 3}, u: 0, p: 0.0809, effect: -1},
+		"equal":     {a: []float64{1, 1, 1}, b: []float64{1, 1, 1}, u: 4.5, p: 1, effect: 0},
+		"ties":      {a: []float64{1, 2, 2, 3}, b: []float64{2, 3, 3, 4}, u: 13, p: 0.1720, effect: 0.625},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			out, ok := MannWhitney(tc.a, tc.b)
+			if !ok {
+				t.Fatalf("expected: %v, got: %v", true, ok)
+			}
+			if math.Abs(out.Statistic-tc.u) > 1e-9 || math.Abs(out.P-tc.p) > 1e-3 || math.Abs(out.Effect-tc.effect) > 1e-9 {
+				t.Fatalf("expected: %v, got: %v", tc, out)
+			}
+		})
+	}
+}
+
+func TestWelch(t *testing.T) {
+	type testCase struct {
+		a, b []float64
+		t    float64
+		p    float64
+	}
+	tests := map[string]testCase{
+		"equal":    {a: []float64{1, 2, 3}, b: []float64{1, 2, 3}, t: 0, p: 1},
+		"shifted":  {a: []float64{1, 2, 3, 4}, b: []float64{3, 4, 5, 6}, t: 2.1909, p: 0.0710},
+		"constant": {a: []float64{2, 2}, b: []float64{3, 3}, t: 0, p: 0},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			out, ok := Welch(tc.a, tc.b)
+			if !ok {
+				t.Fatalf("expected: %v, got: %v", true, ok)
+			}
+			if math.Abs(out.Statistic-tc.t) > 1e-3 || math.Abs(out.P-tc.p) > 1e-3 {
+				t.Fatalf("expected: %v, got: %v", tc, out)
+			}
+		})
+	}
+}
+
+func TestIncompleteBeta(t *testing.T) {
+	// Two-sided p-value of the 97.5% quantile of Student's t-distribution with 10 degrees of freedom.
+	x := 10 / (10 + 2.228139*2.228139)
+	if p := incompleteBeta(5, 0.5, x); math.Abs(p-0.05) > 1e-5 {
+		t.Fatalf("expected: %v, got: %v", 0.05, p)
+	}
+	if p := incompleteBeta(1, 1, 0.3); math.Abs(p-0.3) > 1e-12 {
+		t.Fatalf("expected: %v, got: %v", 0.3, p)
+	}
+}
@@ -1,6 +1,14 @@
 //go:generate mockgen --destination mocks/mock_config.go github.com/sbaeurle/comb/metrics/config Logger
 package config
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
 type EndpointConfig struct {
 	Name    string
 	Url     string
@@ -10,16 +18,188 @@ type EndpointConfig struct {
 	Fields  []string
 	Outputs []string
 	Metrics map[string][]string
+	GroupBy []string
+	// Fields holding timestamps of the sending node, which are corrected by
+	// the estimated clock offset of that node.
+	Timestamps    []string
+	TimestampUnit string
+	// Fields charted live on the dashboard.
+	Dashboard    []string
+	Completeness CompletenessConfig
+	// Fields computed per sample from arithmetic expressions over the other
+	// fields, e.g. "total-time: processing-time + encoding-time".
+	Derived map[string]string
+	// Kinds and units of fields, fields are independent samples by default.
+	FieldTypes map[string]FieldType
+	// Stages of the CHAIN module, each stage processes the results of the
+	// previous one.
+	Stages []StageConfig
+}
+
+// StageConfig configures a stage of a processing chain. Stages use a module
+// like an endpoint, the first stage receives the measurements of the
+// endpoint, the others the results of the previous stage.
+type StageConfig struct {
+	Name       string
+	Module     string
+	Header     bool
+	Config     map[string]string
+	Fields     []string
+	Outputs    []string
+	Metrics    map[string][]string
+	GroupBy    []string
+	Derived    map[string]string
+	FieldTypes map[string]FieldType
+}
+
+// FieldType describes the values of a field. Kind is sample (independent
+// values, the default), gauge (a level holding until the next value, e.g. a
+// queue length) or counter (a monotonically increasing count, e.g. skipped
+// frames, which may be reset).
+type FieldType struct {
+	Kind string
+	Unit string
+}
+
+// CompletenessConfig defines when the data of an endpoint suffices to
+// evaluate a run. Violations mark the endpoint FAILED, or WARN if Severity
+// is WARN. MaxGap bounds the time without measurements, including the
+// start and end of the run.
+type CompletenessConfig struct {
+	MinSamples     int
+	MaxGap         time.Duration
+	RequiredFields []string
+	Severity       string
+}
+
+// TLSConfig enables HTTPS for the metric service. Setting ClientCAFile
+// additionally requires clients to present a certificate signed by that CA.
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// LayoutConfig holds the Go templates naming the result folders. The
+// benchmark folder may use .Name and .Date, run folders additionally .Run,
+// .Matching.<workload> and .Workloads.<workload>.Node/.Tag.
+type LayoutConfig struct {
+	Benchmark string
+	Run       string
+}
+
+// SummaryConfig orders the leaderboard written at the end of a benchmark by
+// a metric named "<endpoint>/<metric>", e.g. "tracking/processing-time-AVG".
+type SummaryConfig struct {
+	SortBy     string
+	Descending bool
+}
+
+// CompareConfig configures the comparison of two benchmarks. Changes are
+// significant below the level Alpha (default 0.05).
+type CompareConfig struct {
+	Alpha       float64
+	Regressions []RegressionConfig
 }
+
+// RegressionConfig flags a significant change of Metric by more than
+// Threshold (relative to the baseline, e.g. 0.1 for 10%) in the wrong
+// direction as regression. Lower values are better unless HigherIsBetter is set.
+type RegressionConfig struct {
+	Metric         string
+	Threshold      float64
+	HigherIsBetter bool
+}
+
+// AuthConfig holds the bearer tokens accepted by the metric service.
+// Ingestion and control routes are secured separately, an empty list
+// leaves the corresponding routes open.
+type AuthConfig struct {
+	IngestTokens  []string
+	ControlTokens []string
+}
+
+// RelayConfig configures the relay command. A relay accepts measurements on
+// the endpoint routes near the workloads, stores them in SpoolFolder and
+// forwards them in batches of up to BatchSize every Interval to the
+// upstream metric service, authenticated by Token. CAFile verifies the
+// certificate of the upstream. DrainTimeout is set on the metric service, it
+// waits at most this long at the end of a run for relays to forward the
+// measurements of the run.
+type RelayConfig struct {
+	Upstream     string
+	Token        string
+	CAFile       string
+	SpoolFolder  string
+	BatchSize    int
+	Interval     time.Duration
+	DrainTimeout time.Duration
+}
+
 type Config struct {
-	Port           int
-	BufferSize     int
-	DateConfig     string
-	GeneratePlots  bool
-	Endpoints      []EndpointConfig
-	RootFolder     string
-	PlottingScript string
+	Host          string
+	Port          int
+	BufferSize    int
+	DateFormat    string
+	GeneratePlots bool
+	Layout        LayoutConfig
+	Summary       SummaryConfig
+	Compare       CompareConfig
+	TLS           TLSConfig
+	Auth          AuthConfig
+	Endpoints     []EndpointConfig
+	RootFolder    string
+	// SnapshotInterval periodically aggregates the metrics of running runs, 0 disables it.
+	SnapshotInterval time.Duration
+	Relay            RelayConfig
+}
+
+// Redacted returns a copy of the configuration without secrets, e.g. to
+// store it alongside the results.
+func (c Config) Redacted() Config {
+	c.Auth = AuthConfig{
+		IngestTokens:  redact(c.Auth.IngestTokens),
+		ControlTokens: redact(c.Auth.ControlTokens),
+	}
+	if c.Relay.Token != "" {
+		c.Relay.Token = "<redacted>"
+	}
+	return c
 }
+
+// Version identifies the content of the configuration, e.g. to tell which
+// configuration a run used after the configuration was reloaded.
+func (c Config) Version() string {
+	tmp, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(tmp)
+	return hex.EncodeToString(sum[:6])
+}
+
+// SortedMetrics returns the metrics of an aggregation mapping sorted by name,
+// e.g. to report them in a stable order.
+func SortedMetrics(metrics map[string][]string) []string {
+	names := make([]string, 0, len(metrics))
+	for m := range metrics {
+		names = append(names, m)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func redact(secrets []string) []string {
+	if secrets == nil {
+		return nil
+	}
+	out := make([]string, len(secrets))
+	for i := range out {
+		out[i] = "<redacted>"
+	}
+	return out
+}
+
 type Logger interface {
 	Debug(args ...interface{})
 	Info(args ...interface{})
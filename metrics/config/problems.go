@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sbaeurle/comb/lib/configfile"
+)
+
+// Problem is an invalid setting of the configuration. Path names the setting
+// like the configuration file, e.g. "Endpoints[1].Metrics.processing-time",
+// File and Line where it is set.
+// Warnings do not reject the configuration, e.g. files which are installed
+// separately.
+type Problem struct {
+	Path    string `json:"path"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+	Warning bool   `json:"warning,omitempty"`
+}
+
+func (p Problem) String() string {
+	s := fmt.Sprintf("%s: %s", p.Path, p.Message)
+	if p.Line > 0 {
+		s = fmt.Sprintf("line %d: %s", p.Line, s)
+	}
+	if p.File != "" {
+		s = fmt.Sprintf("%s: %s", p.File, s)
+	}
+	if p.Warning {
+		s = "warning: " + s
+	}
+	return s
+}
+
+// Problems lists all problems found in a configuration.
+type Problems []Problem
+
+func (p Problems) Error() string {
+	lines := make([]string, len(p))
+	for i, v := range p {
+		lines[i] = v.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Errors returns the problems which are not warnings, nil if there are none.
+func (p Problems) Errors() Problems {
+	var invalid Problems
+	for _, v := range p {
+		if !v.Warning {
+			invalid = append(invalid, v)
+		}
+	}
+	return invalid
+}
+
+// Locate sets the file and line of every problem from the loaded
+// configuration doc, i.e. the included file, profile or overlay setting it.
+// Settings which are not part of it (e.g. defaults) keep the line of their
+// closest parent.
+func (p Problems) Locate(doc *configfile.Document) {
+	for i := range p {
+		p[i].File, p[i].Line = doc.Locate(p[i].Path)
+	}
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sbaeurle/comb/lib/configfile"
+)
+
+func TestLocate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"base.yaml":   "Layout:\n  Run: \"{{.Run\"\n",
+		"config.yaml": "Include: base.yaml\nRootFolder: results\n",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	doc, err := configfile.Load(filepath.Join(dir, "config.yaml"), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	problems := Problems{{Path: "Layout.Run", Message: "invalid template"}}
+	problems.Locate(doc)
+	expected := filepath.Join(dir, "base.yaml") + ": line 2: Layout.Run: invalid template"
+	if problems[0].String() != expected {
+		t.Fatalf("expected: %v, got: %v", expected, problems[0].String())
+	}
+}
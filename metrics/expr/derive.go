@@ -0,0 +1,85 @@
+package expr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Derivation computes derived fields from the fields of a measurement.
+// Derived fields may refer to other derived fields.
+type Derivation struct {
+	names []string
+	exprs []*Expr
+}
+
+// Compile parses the definitions of derived fields (name to expression) and
+// orders them so that every field is derived after the fields it uses.
+func Compile(defs map[string]string) (*Derivation, error) {
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	exprs := make(map[string]*Expr, len(defs))
+	for _, name := range names {
+		e, err := Parse(defs[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		exprs[name] = e
+	}
+
+	d := &Derivation{}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("%s: cyclic definition %s", name, strings.Join(append(path, name), " -> "))
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		for _, f := range exprs[name].Fields() {
+			if _, ok := exprs[f]; ok {
+				err := visit(f, append(path, name))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = done
+		d.names = append(d.names, name)
+		d.exprs = append(d.exprs, exprs[name])
+		return nil
+	}
+	for _, name := range names {
+		err := visit(name, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// Apply adds the derived fields to values. Fields which cannot be derived,
+// e.g. because a field is missing, are left out. It is safe to call Apply
+// on a nil Derivation.
+func (d *Derivation) Apply(values map[string]float64) {
+	if d == nil {
+		return
+	}
+	for i, e := range d.exprs {
+		v, err := e.Eval(values)
+		if err != nil {
+			continue
+		}
+		values[d.names[i]] = v
+	}
+}
@@ -0,0 +1,431 @@
+// Package expr evaluates arithmetic expressions over the fields of a
+// measurement, e.g. "processing-time + encoding-time".
+//
+// Field names may contain hyphens like the fields sent by the workloads, so
+// subtraction has to be separated by spaces: "a - b" subtracts, "a-b" is a
+// field. Expressions support + - * / %, parentheses, numbers and the
+// functions abs, min, max and sqrt. Comparisons (< <= > >= == !=) and the
+// logical operators && and || evaluate to 1 for true and 0 for false.
+package expr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a parsed expression.
+type Expr struct {
+	src    string
+	root   node
+	fields []string
+}
+
+// Parse parses the expression src.
+func Parse(src string) (*Expr, error) {
+	p := &parser{src: src}
+	p.next()
+	root, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+
+	e := &Expr{src: src, root: root}
+	seen := make(map[string]bool)
+	collectFields(root, func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			e.fields = append(e.fields, name)
+		}
+	})
+	sort.Strings(e.fields)
+	return e, nil
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Fields returns the fields the expression refers to.
+func (e *Expr) Fields() []string {
+	return e.fields
+}
+
+// Eval evaluates the expression. Missing fields and results which are not
+// finite, e.g. after a division by zero, are errors.
+func (e *Expr) Eval(values map[string]float64) (float64, error) {
+	out, err := e.root.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(out) || math.IsInf(out, 0) {
+		return 0, fmt.Errorf("%s is not finite", e.src)
+	}
+	return out, nil
+}
+
+type node interface {
+	eval(values map[string]float64) (float64, error)
+}
+
+type number float64
+
+func (n number) eval(map[string]float64) (float64, error) {
+	return float64(n), nil
+}
+
+type field string
+
+func (f field) eval(values map[string]float64) (float64, error) {
+	v, ok := values[string(f)]
+	if !ok {
+		return 0, fmt.Errorf("field %s missing", string(f))
+	}
+	return v, nil
+}
+
+type unary struct {
+	x node
+}
+
+func (u unary) eval(values map[string]float64) (float64, error) {
+	x, err := u.x.eval(values)
+	return -x, err
+}
+
+type binary struct {
+	op   string
+	x, y node
+}
+
+func (b binary) eval(values map[string]float64) (float64, error) {
+	x, err := b.x.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	y, err := b.y.eval(values)
+	if err != nil {
+		return 0, err
+	}
+
+	switch b.op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/":
+		return x / y, nil
+	case "%":
+		return math.Mod(x, y), nil
+	case "<":
+		return truth(x < y), nil
+	case "<=":
+		return truth(x <= y), nil
+	case ">":
+		return truth(x > y), nil
+	case ">=":
+		return truth(x >= y), nil
+	case "==":
+		return truth(x == y), nil
+	case "!=":
+		return truth(x != y), nil
+	case "&&":
+		return truth(x != 0 && y != 0), nil
+	default:
+		return truth(x != 0 || y != 0), nil
+	}
+}
+
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type call struct {
+	fn   string
+	args []node
+}
+
+// functions maps the available functions to their number of arguments.
+var functions = map[string]int{"abs": 1, "sqrt": 1, "min": 2, "max": 2}
+
+func (c call) eval(values map[string]float64) (float64, error) {
+	args := make([]float64, len(c.args))
+	for i, a := range c.args {
+		var err error
+		args[i], err = a.eval(values)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	switch c.fn {
+	case "abs":
+		return math.Abs(args[0]), nil
+	case "sqrt":
+		return math.Sqrt(args[0]), nil
+	case "min":
+		return math.Min(args[0], args[1]), nil
+	default:
+		return math.Max(args[0], args[1]), nil
+	}
+}
+
+func collectFields(n node, add func(string)) {
+	switch n := n.(type) {
+	case field:
+		add(string(n))
+	case unary:
+		collectFields(n.x, add)
+	case binary:
+		collectFields(n.x, add)
+		collectFields(n.y, add)
+	case call:
+		for _, a := range n.args {
+			collectFields(a, add)
+		}
+	}
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value float64
+	pos   int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+type parser struct {
+	src string
+	pos int
+	tok token
+	err error
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s: column %d: %s", p.src, p.tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+// next scans the next token into p.tok.
+func (p *parser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+
+	c := rune(p.src[p.pos])
+	switch {
+	case unicode.IsDigit(c) || c == '.':
+		end := p.pos
+		for end < len(p.src) && (isDigit(p.src[end]) || p.src[end] == '.' ||
+			p.src[end] == 'e' || p.src[end] == 'E' ||
+			((p.src[end] == '+' || p.src[end] == '-') && end > start && (p.src[end-1] == 'e' || p.src[end-1] == 'E'))) {
+			end++
+		}
+		text := p.src[start:end]
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil && p.err == nil {
+			p.err = fmt.Errorf("%s: column %d: invalid number %q", p.src, start+1, text)
+		}
+		p.pos = end
+		p.tok = token{kind: tokNumber, text: text, value: v, pos: start}
+	case unicode.IsLetter(c) || c == '_':
+		end := p.pos
+		for end < len(p.src) && isIdent(p.src[end]) {
+			end++
+		}
+		p.pos = end
+		p.tok = token{kind: tokIdent, text: p.src[start:end], pos: start}
+	default:
+		p.pos++
+		if p.pos < len(p.src) && isOp2(p.src[start:p.pos+1]) {
+			p.pos++
+		}
+		p.tok = token{kind: tokOp, text: p.src[start:p.pos], pos: start}
+	}
+}
+
+// isOp2 reports whether op is an operator of two characters.
+func isOp2(op string) bool {
+	switch op {
+	case "<=", ">=", "==", "!=", "&&", "||":
+		return true
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdent(c byte) bool {
+	return isDigit(c) || c == '_' || c == '-' || unicode.IsLetter(rune(c))
+}
+
+// expression = conjunction { "||" conjunction }
+func (p *parser) expression() (node, error) {
+	return p.binary(p.conjunction, "||")
+}
+
+// conjunction = comparison { "&&" comparison }
+func (p *parser) conjunction() (node, error) {
+	return p.binary(p.comparison, "&&")
+}
+
+// comparison = sum [ ("<" | "<=" | ">" | ">=" | "==" | "!=") sum ]
+func (p *parser) comparison() (node, error) {
+	x, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokOp || !isOneOf(p.tok.text, []string{"<", "<=", ">", ">=", "==", "!="}) {
+		return x, nil
+	}
+	op := p.tok.text
+	p.next()
+	y, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	return binary{op: op, x: x, y: y}, nil
+}
+
+// sum = term { ("+" | "-") term }
+func (p *parser) sum() (node, error) {
+	return p.binary(p.term, "+", "-")
+}
+
+// term = factor { ("*" | "/" | "%") factor }
+func (p *parser) term() (node, error) {
+	return p.binary(p.factor, "*", "/", "%")
+}
+
+// binary parses operands joined by the left-associative operators ops.
+func (p *parser) binary(operand func() (node, error), ops ...string) (node, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp && isOneOf(p.tok.text, ops) {
+		op := p.tok.text
+		p.next()
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		x = binary{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+func isOneOf(s string, list []string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// factor = "-" factor | number | field | function "(" args ")" | "(" expression ")"
+func (p *parser) factor() (node, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	tok := p.tok
+	switch {
+	case tok.kind == tokOp && tok.text == "-":
+		p.next()
+		x, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return unary{x: x}, nil
+	case tok.kind == tokNumber:
+		p.next()
+		return number(tok.value), p.err
+	case tok.kind == tokIdent:
+		p.next()
+		if p.tok.kind != tokOp || p.tok.text != "(" {
+			if strings.HasSuffix(tok.text, "-") {
+				return nil, fmt.Errorf("%s: column %d: field %q ends with -, separate subtractions by spaces", p.src, tok.pos+1, tok.text)
+			}
+			return field(tok.text), nil
+		}
+		return p.call(tok)
+	case tok.kind == tokOp && tok.text == "(":
+		p.next()
+		x, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokOp || p.tok.text != ")" {
+			return nil, p.errorf("expected ), got %s", p.tok)
+		}
+		p.next()
+		return x, nil
+	}
+	return nil, p.errorf("unexpected %s", tok)
+}
+
+func (p *parser) call(fn token) (node, error) {
+	n, ok := functions[fn.text]
+	if !ok {
+		return nil, fmt.Errorf("%s: column %d: unknown function %s", p.src, fn.pos+1, fn.text)
+	}
+
+	// Skip the opening parenthesis.
+	p.next()
+	var args []node
+	for {
+		arg, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.tok.kind == tokOp && p.tok.text == "," {
+			p.next()
+			continue
+		}
+		break
+	}
+	if p.tok.kind != tokOp || p.tok.text != ")" {
+		return nil, p.errorf("expected ), got %s", p.tok)
+	}
+	p.next()
+
+	if len(args) != n {
+		return nil, fmt.Errorf("%s: column %d: %s expects %d arguments, got %d", p.src, fn.pos+1, fn.text, n, len(args))
+	}
+	return call{fn: fn.text, args: args}, nil
+}
@@ -0,0 +1,112 @@
+package expr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	values := map[string]float64{"processing-time": 20, "encoding-time": 5, "objects": 4, "zero": 0}
+
+	type testCase struct {
+		src    string
+		out    float64
+		fields []string
+		err    string
+	}
+	tests := map[string]testCase{
+		"sum":         {src: "processing-time + encoding-time", out: 25, fields: []string{"encoding-time", "processing-time"}},
+		"subtraction": {src: "processing-time - encoding-time", out: 15, fields: []string{"encoding-time", "processing-time"}},
+		"precedence":  {src: "2 + objects * 3 % 5", out: 4, fields: []string{"objects"}},
+		"parentheses": {src: "(processing-time + encoding-time) / objects", out: 6.25, fields: []string{"encoding-time", "objects", "processing-time"}},
+		"unary":       {src: "-objects * -2", out: 8, fields: []string{"objects"}},
+		"functions":   {src: "max(abs(-3), sqrt(objects)) + min(1e1, .5)", out: 3.5},
+		"comparison":  {src: "processing-time + 1 > encoding-time * 4", out: 1},
+		"equality":    {src: "objects == 4 && zero != 0", out: 0},
+		"logical":     {src: "objects < 2 || processing-time >= 20 && encoding-time <= 5", out: 1},
+		"assignment":  {src: "objects = 4", err: `unexpected "="`},
+		"hyphen":      {src: "processing-time-encoding-time", err: "field processing-time-encoding-time missing"},
+		"missing":     {src: "latency * 2", err: "field latency missing"},
+		"division":    {src: "objects / zero", err: "not finite"},
+		"trailing -":  {src: "objects- 1", err: "separate subtractions by spaces"},
+		"syntax":      {src: "objects +", err: "unexpected end of expression"},
+		"parenthesis": {src: "(objects + 1", err: "expected ), got end of expression"},
+		"function":    {src: "log(objects)", err: "unknown function log"},
+		"arguments":   {src: "min(objects)", err: "min expects 2 arguments, got 1"},
+		"character":   {src: "objects ^ 2", err: `unexpected "^"`},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := Parse(tc.src)
+			var out float64
+			if err == nil {
+				if tc.fields != nil && !reflect.DeepEqual(tc.fields, e.Fields()) {
+					t.Fatalf("expected: %v, got: %v", tc.fields, e.Fields())
+				}
+				out, err = e.Eval(values)
+			}
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected: %v, got: %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != tc.out {
+				t.Fatalf("expected: %v, got: %v", tc.out, out)
+			}
+		})
+	}
+}
+
+func TestDerivation(t *testing.T) {
+	type testCase struct {
+		defs map[string]string
+		in   map[string]float64
+		out  map[string]float64
+		err  string
+	}
+	tests := map[string]testCase{
+		"dependencies": {
+			defs: map[string]string{"a-total": "b-total * 2", "b-total": "x + y"},
+			in:   map[string]float64{"x": 1, "y": 2},
+			out:  map[string]float64{"x": 1, "y": 2, "a-total": 6, "b-total": 3},
+		},
+		"missing field": {
+			defs: map[string]string{"total": "x + y", "ratio": "x / y"},
+			in:   map[string]float64{"x": 1},
+			out:  map[string]float64{"x": 1},
+		},
+		"cycle": {
+			defs: map[string]string{"a": "b + 1", "b": "c + 1", "c": "a + 1"},
+			err:  "a: cyclic definition a -> b -> c -> a",
+		},
+		"syntax": {
+			defs: map[string]string{"a": "x +"},
+			err:  "a: x +: column 4: unexpected end of expression",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, err := Compile(tc.defs)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected: %v, got: %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			d.Apply(tc.in)
+			if !reflect.DeepEqual(tc.out, tc.in) {
+				t.Fatalf("expected: %v, got: %v", tc.out, tc.in)
+			}
+		})
+	}
+}
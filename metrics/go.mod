@@ -4,11 +4,18 @@ go 1.16
 
 require (
 	github.com/d5/tengo/v2 v2.10.0
+	github.com/fsnotify/fsnotify v1.5.1
 	github.com/golang/mock v1.6.0
 	github.com/gorilla/mux v1.8.0
+	github.com/sbaeurle/comb/lib v0.1.0
 	github.com/spf13/cobra v1.3.0
 	github.com/spf13/viper v1.10.0
 	go.uber.org/atomic v1.8.0 // indirect
 	go.uber.org/multierr v1.7.0 // indirect
 	go.uber.org/zap v1.18.1
+	gopkg.in/yaml.v2 v2.4.0
 )
+
+// The lib module is developed in this repository, builds use its checkout until
+// lib/v0.1.0 is tagged.
+replace github.com/sbaeurle/comb/lib => ../lib
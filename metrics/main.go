@@ -1,9 +1,15 @@
 package main
 
 import (
+	"os"
+
 	"github.com/sbaeurle/comb/metrics/cmd"
 )
 
 func main() {
-	cmd.Execute()
+	// Commands print their error, failures only have to be reflected in the
+	// exit status, e.g. to fail CI on regressions.
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
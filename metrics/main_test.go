@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sbaeurle/comb/metrics/results"
+)
+
+// argsEnv holds the arguments of the command line when the test binary is
+// re-executed to check the exit status of main.
+const argsEnv = "METRICS_TEST_ARGS"
+
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv(argsEnv); ok {
+		os.Args = append([]string{"metrics"}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// run executes main with args and returns its exit status.
+func run(t *testing.T, args ...string) int {
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), argsEnv+"="+strings.Join(args, "\n"))
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return exit.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return 0
+}
+
+func writeFile(t *testing.T, path string, content string) string {
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func writeBenchmark(t *testing.T, values ...float64) string {
+	root := t.TempDir()
+	for i, v := range values {
+		path := filepath.Join(root, fmt.Sprintf("run%03d", i+1))
+		err := os.Mkdir(path, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = results.Write(path, results.Results{
+			Matching: map[string]string{"Tracking": "APU-10.0.0.3-cpu"},
+			Results:  map[string]map[string]float64{"tracking": {"processing-time-AVG": v}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestExitStatus(t *testing.T) {
+	dir := t.TempDir()
+	cfg := writeFile(t, filepath.Join(dir, "config.yaml"), `RootFolder: results
+Endpoints:
+  - Name: tracking
+    Url: /tracking
+    Module: GENERIC
+    Fields: [processing-time]
+    Metrics:
+      processing-time: [AVG]
+Compare:
+  Regressions:
+    - Metric: tracking/processing-time-AVG
+      Threshold: 0.1
+`)
+	invalid := writeFile(t, filepath.Join(dir, "invalid.yaml"), `Endpoints:
+  - Name: tracking
+    Url: tracking
+    Module: GENERIC
+`)
+	baseline := writeBenchmark(t, 10, 11, 12)
+
+	type testCase struct {
+		args   []string
+		status int
+	}
+	tests := map[string]testCase{
+		"no regression": {args: []string{"compare", baseline, writeBenchmark(t, 9, 10, 11), "--config", cfg}, status: 0},
+		"regression":    {args: []string{"compare", baseline, writeBenchmark(t, 20, 21, 22), "--config", cfg}, status: 1},
+		"usage":         {args: []string{"compare", baseline, "--config", cfg}, status: 1},
+		"valid":         {args: []string{"validate", "--config", cfg}, status: 0},
+		"invalid":       {args: []string{"validate", "--config", invalid}, status: 1},
+		// TrackEval is installed separately, its missing script is a warning.
+		"sample": {args: []string{"validate", "--config", "config.yaml"}, status: 0},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			status := run(t, tc.args...)
+			if status != tc.status {
+				t.Fatalf("expected: %v, got: %v", tc.status, status)
+			}
+		})
+	}
+}
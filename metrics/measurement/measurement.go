@@ -0,0 +1,75 @@
+package measurement
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Labels available to tag measurements with their origin.
+const (
+	LabelNode      = "node"
+	LabelWorkload  = "workload"
+	LabelContainer = "container"
+	LabelAddress   = "address"
+)
+
+// Prefix used to reference source labels in the Fields of an endpoint.
+const FieldPrefix = "source."
+
+// TimestampUnits maps the TimestampUnit of an endpoint to its duration.
+var TimestampUnits = map[string]time.Duration{
+	"":   time.Millisecond,
+	"s":  time.Second,
+	"ms": time.Millisecond,
+	"us": time.Microsecond,
+	"ns": time.Nanosecond,
+}
+
+// Source identifies the sender of a measurement.
+type Source struct {
+	Node      string `json:"node,omitempty"`
+	Workload  string `json:"workload,omitempty"`
+	Container string `json:"container,omitempty"`
+	Address   string `json:"address,omitempty"`
+}
+
+// Measurement is a single sample received on a benchmark endpoint.
+type Measurement struct {
+	Source   Source
+	Received time.Time
+	Body     []byte
+}
+
+// Label returns the value of the given source label.
+func (s Source) Label(label string) string {
+	switch label {
+	case LabelNode:
+		return s.Node
+	case LabelWorkload:
+		return s.Workload
+	case LabelContainer:
+		return s.Container
+	case LabelAddress:
+		return s.Address
+	}
+	return ""
+}
+
+// Group builds the key of the group a source belongs to, e.g. "node=jetson,container=tracking".
+func (s Source) Group(labels []string) string {
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = fmt.Sprintf("%s=%s", l, s.Label(l))
+	}
+	return strings.Join(parts, ",")
+}
+
+// ValidLabel reports whether label is a known source label.
+func ValidLabel(label string) bool {
+	switch label {
+	case LabelNode, LabelWorkload, LabelContainer, LabelAddress:
+		return true
+	}
+	return false
+}
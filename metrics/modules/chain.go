@@ -0,0 +1,237 @@
+package modules
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/measurement"
+	"github.com/sbaeurle/comb/metrics/outputs"
+)
+
+// Chain processes the measurements of an endpoint by a sequence of stages.
+// Every stage but the last passes its results on to the next one, the
+// metrics of all stages are collected.
+type Chain struct {
+	log    config.Logger
+	cfg    config.EndpointConfig
+	input  chan measurement.Measurement
+	stages []Module
+	// inputs of the stages after the first one.
+	inputs []chan measurement.Measurement
+}
+
+// forwarder is implemented by modules usable before the last stage of a
+// chain. They write their results to next like to their outputs.
+type forwarder interface {
+	forward(next outputs.Output)
+}
+
+// stageOutput passes results on to the next stage of a chain.
+type stageOutput struct {
+	next chan measurement.Measurement
+}
+
+func (o stageOutput) WriteResult(src measurement.Source, received time.Time, out map[string]float64) error {
+	body, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	o.next <- measurement.Measurement{Source: src, Received: received, Body: body}
+	return nil
+}
+
+func init() {
+	Modules["CHAIN"] = NewChain
+	Validators["CHAIN"] = validateChain
+}
+
+// validateChain checks the stages with the validators of their modules.
+func validateChain(cfg config.EndpointConfig) []config.Problem {
+	var problems []config.Problem
+	add := func(path string, format string, args ...interface{}) {
+		problems = append(problems, config.Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(cfg.Stages) == 0 {
+		add("Stages", "missing")
+	}
+	if len(cfg.Outputs) > 0 {
+		add("Outputs", "outputs of a chain are set on its stages")
+	}
+	if len(cfg.Metrics) > 0 {
+		add("Metrics", "metrics of a chain are set on its stages")
+	}
+
+	names := make(map[string]bool)
+	metrics := make(map[string]string)
+	outputz := make(map[string]bool)
+	for i, stage := range cfg.Stages {
+		path := fmt.Sprintf("Stages[%d]", i)
+		name := stageName(cfg, i)
+		if names[name] {
+			add(path+".Name", "duplicate stage %s", name)
+		}
+		names[name] = true
+
+		if stage.Module == "CHAIN" {
+			add(path+".Module", "chains cannot be nested")
+		} else if _, ok := Modules[stage.Module]; !ok {
+			add(path+".Module", "unknown module %s", stage.Module)
+		} else if validate, ok := Validators[stage.Module]; ok {
+			for _, p := range validate(stageConfig(cfg, i)) {
+				add(path+"."+p.Path, "%s", p.Message)
+			}
+		}
+
+		for _, m := range config.SortedMetrics(stage.Metrics) {
+			if other, ok := metrics[m]; ok {
+				add(path+".Metrics."+m, "metric %s is also aggregated by stage %s", m, other)
+			}
+			metrics[m] = name
+		}
+		for j, o := range stage.Outputs {
+			if outputz[o] {
+				add(fmt.Sprintf("%s.Outputs[%d]", path, j), "output %s is also written by another stage", o)
+			}
+			outputz[o] = true
+		}
+	}
+	return problems
+}
+
+// stageName names stage i of cfg, by default after its position.
+func stageName(cfg config.EndpointConfig, i int) string {
+	name := cfg.Stages[i].Name
+	if name == "" {
+		name = fmt.Sprint(i)
+	}
+	return fmt.Sprintf("%s/%s", cfg.Name, name)
+}
+
+// stageConfig returns the endpoint configuration the module of stage i uses.
+func stageConfig(cfg config.EndpointConfig, i int) config.EndpointConfig {
+	s := cfg.Stages[i]
+	return config.EndpointConfig{
+		Name:          stageName(cfg, i),
+		Url:           cfg.Url,
+		Module:        s.Module,
+		Header:        s.Header,
+		Config:        s.Config,
+		Fields:        s.Fields,
+		Outputs:       s.Outputs,
+		Metrics:       s.Metrics,
+		GroupBy:       s.GroupBy,
+		TimestampUnit: cfg.TimestampUnit,
+		Derived:       s.Derived,
+		FieldTypes:    s.FieldTypes,
+	}
+}
+
+// NewChain creates the modules of the stages of cfg. A chain ends early at a
+// stage which cannot be created or cannot pass on its results.
+func NewChain(log config.Logger, cfg config.EndpointConfig, input chan measurement.Measurement) Module {
+	c := &Chain{log: log, cfg: cfg, input: input}
+
+	next := input
+	for i, stage := range cfg.Stages {
+		mod, ok := Modules[stage.Module]
+		if !ok || stage.Module == "CHAIN" {
+			log.Errorf("%s: chain ends before stage %s: module %s not usable", cfg.Name, stageName(cfg, i), stage.Module)
+			break
+		}
+		if i > 0 {
+			f, ok := c.stages[i-1].(forwarder)
+			if !ok {
+				log.Errorf("%s: chain ends before stage %s: module %s cannot pass on results", cfg.Name, stageName(cfg, i), cfg.Stages[i-1].Module)
+				break
+			}
+			next = make(chan measurement.Measurement, cap(input))
+			f.forward(stageOutput{next: next})
+			c.inputs = append(c.inputs, next)
+		}
+		c.stages = append(c.stages, mod(log, stageConfig(cfg, i), next))
+	}
+	return c
+}
+
+func (c *Chain) StartMeasurement(path string) error {
+	for _, stage := range c.stages {
+		err := stage.StartMeasurement(path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddMeasurements runs the stages until the input of the chain is closed.
+// The input of a stage is closed once the previous stage has finished.
+func (c *Chain) AddMeasurements() {
+	if len(c.stages) == 0 {
+		for range c.input {
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i, stage := range c.stages {
+		wg.Add(1)
+		go func(i int, stage Module) {
+			defer wg.Done()
+			stage.AddMeasurements()
+			if i < len(c.inputs) {
+				close(c.inputs[i])
+			}
+		}(i, stage)
+	}
+	wg.Wait()
+}
+
+// Join joins the stages implementing Joiner, they see the metrics of the
+// chain as their own.
+func (c *Chain) Join(collected map[string]map[string]float64, samples map[string]int) map[string]float64 {
+	out := make(map[string]float64)
+	for i, stage := range c.stages {
+		j, ok := stage.(Joiner)
+		if !ok {
+			continue
+		}
+		name := stageName(c.cfg, i)
+		view := make(map[string]map[string]float64, len(collected)+1)
+		for k, v := range collected {
+			view[k] = v
+		}
+		view[name] = collected[c.cfg.Name]
+		for k, v := range j.Join(view, samples) {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// Snapshots is false if any stage cannot be aggregated while the run continues.
+func (c *Chain) Snapshots() bool {
+	for _, stage := range c.stages {
+		if s, ok := stage.(Snapshotter); ok && !s.Snapshots() {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Chain) CollectMetrics() (map[string]float64, error) {
+	out := make(map[string]float64)
+	for i, stage := range c.stages {
+		metrics, err := stage.CollectMetrics()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", stageName(c.cfg, i), err)
+		}
+		for k, v := range metrics {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,139 @@
+package modules
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sbaeurle/comb/metrics/config"
+	mock_config "github.com/sbaeurle/comb/metrics/config/mocks"
+	"github.com/sbaeurle/comb/metrics/measurement"
+)
+
+func TestChain(t *testing.T) {
+	type testCase struct {
+		stages  []config.StageConfig
+		bodies  []string
+		metrics map[string]float64
+	}
+	tests := map[string]testCase{
+		"filter, transform, aggregate": {
+			stages: []config.StageConfig{
+				{Module: "FILTER", Config: map[string]string{"Where": "conf >= 0.5"}, Metrics: map[string][]string{"conf": {"MIN"}}},
+				{Module: "TRANSFORM", Fields: []string{"total-time"}, Derived: map[string]string{"total-time": "processing-time + encoding-time"}},
+				{Module: "GENERIC", Metrics: map[string][]string{"total-time": {"AVG", "MAX"}, "processing-time": {"AVG"}}},
+			},
+			bodies: []string{
+				`{"conf": 0.9, "processing-time": 20, "encoding-time": 5}`,
+				`{"conf": 0.2, "processing-time": 100, "encoding-time": 5}`,
+				`{"conf": 0.6, "processing-time": 30, "encoding-time": 5}`,
+				`{"processing-time": 100, "encoding-time": 5}`,
+			},
+			metrics: map[string]float64{"conf-MIN": 0.6, "total-time-AVG": 30, "total-time-MAX": 35},
+		},
+		"single stage": {
+			stages: []config.StageConfig{
+				{Module: "GENERIC", Metrics: map[string][]string{"processing-time": {"AVG"}}},
+			},
+			bodies:  []string{`{"processing-time": 20}`, `{"processing-time": 30}`},
+			metrics: map[string]float64{"processing-time-AVG": 25},
+		},
+		"unknown module": {
+			stages: []config.StageConfig{
+				{Module: "GENERIC", Metrics: map[string][]string{"processing-time": {"AVG"}}},
+				{Module: "UNKNOWN"},
+			},
+			bodies:  []string{`{"processing-time": 20}`},
+			metrics: map[string]float64{"processing-time-AVG": 20},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.EndpointConfig{Name: "tracking", Module: "CHAIN", Stages: tc.stages}
+			input := make(chan measurement.Measurement, 10)
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockLogger := mock_config.NewMockLogger(mockCtrl)
+			mockLogger.EXPECT().Errorf(gomock.Any(), gomock.Any()).AnyTimes()
+
+			c := NewChain(mockLogger, cfg, input)
+			err := c.StartMeasurement(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			done := make(chan struct{})
+			go func() {
+				c.AddMeasurements()
+				close(done)
+			}()
+
+			for _, b := range tc.bodies {
+				input <- measurement.Measurement{Body: []byte(b)}
+			}
+			close(input)
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("chain did not stop after its input was closed")
+			}
+
+			out, err := c.CollectMetrics()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tc.metrics, out) {
+				t.Fatalf("expected: %v, got: %v", tc.metrics, out)
+			}
+		})
+	}
+}
+
+func TestStageOutputReceived(t *testing.T) {
+	next := make(chan measurement.Measurement, 1)
+	received := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	err := stageOutput{next: next}.WriteResult(measurement.Source{Node: "jetson"}, received, map[string]float64{"a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Later stages see when the measurement arrived, not when it was forwarded.
+	m := <-next
+	if !m.Received.Equal(received) {
+		t.Fatalf("expected: %v, got: %v", received, m.Received)
+	}
+}
+
+func TestChainSnapshots(t *testing.T) {
+	type testCase struct {
+		stages    []config.StageConfig
+		snapshots bool
+	}
+	tests := map[string]testCase{
+		"generic": {
+			stages:    []config.StageConfig{{Module: "FILTER", Config: map[string]string{"Where": "conf >= 0.5"}}, {Module: "GENERIC"}},
+			snapshots: true,
+		},
+		"mot": {
+			stages:    []config.StageConfig{{Module: "FILTER", Config: map[string]string{"Where": "conf >= 0.5"}}, {Module: "MOT"}},
+			snapshots: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockLogger := mock_config.NewMockLogger(mockCtrl)
+
+			cfg := config.EndpointConfig{Name: "tracking", Module: "CHAIN", Stages: tc.stages}
+			c := NewChain(mockLogger, cfg, make(chan measurement.Measurement)).(Snapshotter)
+			if c.Snapshots() != tc.snapshots {
+				t.Fatalf("expected: %v, got: %v", tc.snapshots, c.Snapshots())
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package modules
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/measurement"
+	"github.com/sbaeurle/comb/metrics/outputs"
+)
+
+// Metrics reported by the ENERGY module.
+const (
+	MetricEnergy         = "energy"
+	MetricPowerAvg       = "power-AVG"
+	MetricPowerMax       = "power-MAX"
+	MetricEnergyPerFrame = "energy-per-frame"
+)
+
+// Energy integrates power readings in watts into the energy consumed during
+// a run. Readings are integrated per source using the time they were
+// received, so several power meters may post to the same endpoint.
+type Energy struct {
+	mu      sync.Mutex
+	log     config.Logger
+	cfg     config.EndpointConfig
+	input   chan measurement.Measurement
+	field   string
+	series  map[measurement.Source]*series
+	next    outputs.Output
+	outputz []outputs.Output
+}
+
+func init() {
+	Modules["ENERGY"] = NewEnergy
+	Validators["ENERGY"] = validateEnergy
+	ModuleUnits["ENERGY"] = map[string]string{
+		MetricEnergy:         "J",
+		MetricPowerAvg:       "W",
+		MetricPowerMax:       "W",
+		MetricEnergyPerFrame: "J/frame",
+	}
+}
+
+// validateEnergy checks the frame source of the energy per frame, which is
+// either an endpoint, counting its samples, or a metric "<endpoint>/<metric>".
+func validateEnergy(cfg config.EndpointConfig) []config.Problem {
+	frames := cfg.Config["Frames"]
+	if frames == "" {
+		return nil
+	}
+	parts := strings.SplitN(frames, "/", 2)
+	if parts[0] == "" || (len(parts) == 2 && parts[1] == "") {
+		return []config.Problem{{Path: "Config.Frames", Message: fmt.Sprintf("expected <endpoint> or <endpoint>/<metric>, got %q", frames)}}
+	}
+	if parts[0] == cfg.Name {
+		return []config.Problem{{Path: "Config.Frames", Message: "frames must be counted by another endpoint"}}
+	}
+	return nil
+}
+
+func NewEnergy(log config.Logger, cfg config.EndpointConfig, input chan measurement.Measurement) Module {
+	field := cfg.Config["PowerField"]
+	if field == "" {
+		field = "power"
+	}
+	return &Energy{log: log, cfg: cfg, input: input, field: field, series: make(map[measurement.Source]*series)}
+}
+
+func (e *Energy) StartMeasurement(path string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.series = make(map[measurement.Source]*series)
+
+	e.outputz = make([]outputs.Output, 0)
+	for _, v := range e.cfg.Outputs {
+		out := outputs.Outputz[filepath.Ext(v)]
+		tmp, err := out(e.log, v, path, e.cfg.Fields, e.cfg.Header)
+		if err != nil {
+			return err
+		}
+		e.outputz = append(e.outputz, tmp)
+	}
+	if e.next != nil {
+		e.outputz = append(e.outputz, e.next)
+	}
+	return nil
+}
+
+func (e *Energy) forward(next outputs.Output) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.next = next
+}
+
+func (e *Energy) AddMeasurements() {
+	for v := range e.input {
+		var r results
+		err := json.Unmarshal(v.Body, &r)
+		if err != nil {
+			e.log.Error(err)
+			continue
+		}
+		power, ok := r[e.field]
+		if !ok {
+			e.log.Errorf("%s: measurement without %s", e.cfg.Name, e.field)
+			continue
+		}
+
+		e.mu.Lock()
+		if e.series[v.Source] == nil {
+			e.series[v.Source] = &series{}
+		}
+		e.series[v.Source].add(v.Received, power)
+		e.mu.Unlock()
+
+		for _, out := range e.outputz {
+			out.WriteResult(v.Source, v.Received, r)
+		}
+	}
+}
+
+// CollectMetrics reports the energy in joules, the average power as the sum
+// of the average power of all sources and the largest reading in watts.
+// Metrics per source group are prefixed with the group like by GENERIC.
+func (e *Energy) CollectMetrics() (map[string]float64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var all []*series
+	groups := make(map[string][]*series)
+	for src, s := range e.series {
+		all = append(all, s)
+		if len(e.cfg.GroupBy) > 0 {
+			key := src.Group(e.cfg.GroupBy)
+			groups[key] = append(groups[key], s)
+		}
+	}
+
+	out := integratePower(all)
+	for g, group := range groups {
+		for m, v := range integratePower(group) {
+			out[fmt.Sprintf("%s/%s", g, m)] = v
+		}
+	}
+	return out, nil
+}
+
+// Join adds the energy per frame. Frames are the samples of an endpoint or
+// a metric "<endpoint>/<metric>", e.g. the TOTAL of a frame counter.
+func (e *Energy) Join(collected map[string]map[string]float64, samples map[string]int) map[string]float64 {
+	frames := e.cfg.Config["Frames"]
+	energy, ok := collected[e.cfg.Name][MetricEnergy]
+	if frames == "" || !ok {
+		return nil
+	}
+
+	var n float64
+	parts := strings.SplitN(frames, "/", 2)
+	if len(parts) == 1 {
+		n = float64(samples[parts[0]])
+	} else {
+		n, ok = collected[parts[0]][parts[1]]
+		if !ok {
+			e.log.Warnf("%s: energy per frame: metric %s not collected", e.cfg.Name, frames)
+			return nil
+		}
+	}
+	// The energy per frame is undefined without frames.
+	if n <= 0 {
+		return nil
+	}
+	return map[string]float64{MetricEnergyPerFrame: energy / n}
+}
+
+// integratePower integrates the power of every series with the trapezoidal
+// rule. The average and peak power are those of the summed power of all
+// series, aligned by the times of their readings. Outside the time a series
+// reports, it keeps its first or last reading.
+func integratePower(all []*series) map[string]float64 {
+	out := make(map[string]float64)
+	var times []time.Time
+	energy := 0.0
+	for _, s := range all {
+		times = append(times, s.times...)
+		for i := 1; i < len(s.values); i++ {
+			dt := s.times[i].Sub(s.times[i-1]).Seconds()
+			energy += (s.values[i] + s.values[i-1]) / 2 * dt
+		}
+	}
+	if len(times) == 0 {
+		return out
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	// The summed power is linear between the readings of all series, so its
+	// peak is at one of them.
+	total, peak := 0.0, math.Inf(-1)
+	var prev float64
+	for i, t := range times {
+		sum := 0.0
+		for _, s := range all {
+			sum += s.at(t)
+		}
+		peak = math.Max(peak, sum)
+		if i > 0 {
+			total += (sum + prev) / 2 * t.Sub(times[i-1]).Seconds()
+		}
+		prev = sum
+	}
+
+	out[MetricPowerMax] = peak
+	if d := times[len(times)-1].Sub(times[0]); d > 0 {
+		out[MetricEnergy] = energy
+		out[MetricPowerAvg] = total / d.Seconds()
+	}
+	return out
+}
@@ -0,0 +1,155 @@
+package modules
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sbaeurle/comb/metrics/config"
+	mock_config "github.com/sbaeurle/comb/metrics/config/mocks"
+	"github.com/sbaeurle/comb/metrics/measurement"
+)
+
+func TestEnergy(t *testing.T) {
+	type reading struct {
+		node  string
+		at    time.Duration
+		power float64
+	}
+	type testCase struct {
+		config    map[string]string
+		groupBy   []string
+		readings  []reading
+		collected map[string]map[string]float64
+		samples   map[string]int
+		out       map[string]float64
+	}
+	tests := map[string]testCase{
+		"single meter": {
+			config: map[string]string{"Frames": "tracking"},
+			readings: []reading{
+				{at: 0, power: 10},
+				{at: 2 * time.Second, power: 20},
+				{at: 4 * time.Second, power: 10},
+			},
+			samples: map[string]int{"tracking": 120},
+			out: map[string]float64{
+				MetricEnergy:         60,
+				MetricPowerAvg:       15,
+				MetricPowerMax:       20,
+				MetricEnergyPerFrame: 0.5,
+			},
+		},
+		"frame metric": {
+			config: map[string]string{"Frames": "tracking/frames-TOTAL", "PowerField": "watts"},
+			readings: []reading{
+				{at: 0, power: 5},
+				{at: 10 * time.Second, power: 5},
+			},
+			collected: map[string]map[string]float64{"tracking": {"frames-TOTAL": 25}},
+			out: map[string]float64{
+				MetricEnergy:         50,
+				MetricPowerAvg:       5,
+				MetricPowerMax:       5,
+				MetricEnergyPerFrame: 2,
+			},
+		},
+		"by-node": {
+			groupBy: []string{"node"},
+			readings: []reading{
+				{node: "a", at: 0, power: 10},
+				{node: "b", at: 0, power: 4},
+				{node: "a", at: time.Second, power: 10},
+				{node: "b", at: 2 * time.Second, power: 4},
+			},
+			out: map[string]float64{
+				MetricEnergy:               18,
+				MetricPowerAvg:             14,
+				MetricPowerMax:             14,
+				"node=a/" + MetricEnergy:   10,
+				"node=a/" + MetricPowerAvg: 10,
+				"node=a/" + MetricPowerMax: 10,
+				"node=b/" + MetricEnergy:   8,
+				"node=b/" + MetricPowerAvg: 4,
+				"node=b/" + MetricPowerMax: 4,
+			},
+		},
+		"two rails": {
+			// The peaks of the rails do not coincide, the peak of their sum is
+			// above each of them.
+			readings: []reading{
+				{node: "cpu", at: 0, power: 2},
+				{node: "gpu", at: 0, power: 8},
+				{node: "cpu", at: time.Second, power: 10},
+				{node: "gpu", at: time.Second, power: 2},
+				{node: "cpu", at: 2 * time.Second, power: 2},
+				{node: "gpu", at: 2 * time.Second, power: 8},
+			},
+			out: map[string]float64{MetricEnergy: 22, MetricPowerAvg: 11, MetricPowerMax: 12},
+		},
+		"single reading": {
+			config:   map[string]string{"Frames": "tracking"},
+			readings: []reading{{power: 7}},
+			samples:  map[string]int{"tracking": 10},
+			out:      map[string]float64{MetricPowerMax: 7},
+		},
+		"no frames": {
+			config: map[string]string{"Frames": "tracking"},
+			readings: []reading{
+				{at: 0, power: 10},
+				{at: time.Second, power: 10},
+			},
+			out: map[string]float64{MetricEnergy: 10, MetricPowerAvg: 10, MetricPowerMax: 10},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.EndpointConfig{Name: "power", Module: "ENERGY", Config: tc.config, GroupBy: tc.groupBy}
+			input := make(chan measurement.Measurement, 10)
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockLogger := mock_config.NewMockLogger(mockCtrl)
+
+			e := NewEnergy(mockLogger, cfg, input)
+			err := e.StartMeasurement(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			field := tc.config["PowerField"]
+			if field == "" {
+				field = "power"
+			}
+			start := time.Now()
+			for _, r := range tc.readings {
+				body := []byte(fmt.Sprintf(`{"%s": %v}`, field, r.power))
+				input <- measurement.Measurement{Source: measurement.Source{Node: r.node}, Received: start.Add(r.at), Body: body}
+			}
+			close(input)
+			e.AddMeasurements()
+
+			out, err := e.CollectMetrics()
+			if err != nil {
+				t.Fatal(err)
+			}
+			collected := map[string]map[string]float64{"power": out}
+			for k, v := range tc.collected {
+				collected[k] = v
+			}
+			for k, v := range e.(Joiner).Join(collected, tc.samples) {
+				out[k] = v
+			}
+			if !reflect.DeepEqual(tc.out, out) {
+				t.Fatalf("expected: %v, got: %v", tc.out, out)
+			}
+			if avg, ok := out[MetricPowerAvg]; ok && out[MetricPowerMax] < avg {
+				t.Fatalf("expected: %v >= %v, got: %v", MetricPowerMax, avg, out[MetricPowerMax])
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package modules
+
+import (
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/expr"
+	"github.com/sbaeurle/comb/metrics/measurement"
+)
+
+func init() {
+	Modules["FILTER"] = NewFilter
+	Validators["FILTER"] = validateFilter
+}
+
+// validateFilter checks the condition in Config.Where.
+func validateFilter(cfg config.EndpointConfig) []config.Problem {
+	problems := validateFields(cfg)
+	if cfg.Config["Where"] == "" {
+		return append(problems, config.Problem{Path: "Config.Where", Message: "missing"})
+	}
+	if _, err := expr.Parse(cfg.Config["Where"]); err != nil {
+		problems = append(problems, config.Problem{Path: "Config.Where", Message: err.Error()})
+	}
+	return problems
+}
+
+// NewFilter creates a module which drops measurements unless the expression
+// in Config.Where is true (not 0) for them, e.g. "conf >= 0.5". Measurements
+// missing a field of the expression are dropped. The others are handled like
+// by GENERIC.
+func NewFilter(log config.Logger, cfg config.EndpointConfig, input chan measurement.Measurement) Module {
+	g := NewGeneric(log, cfg, input).(*Generic)
+	where, err := expr.Parse(cfg.Config["Where"])
+	if err != nil {
+		log.Errorf("%s: filter disabled: Where: %v", cfg.Name, err)
+		return g
+	}
+	g.where = where
+	return g
+}
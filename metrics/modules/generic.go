@@ -6,6 +6,8 @@ import (
 	"sync"
 
 	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/expr"
+	"github.com/sbaeurle/comb/metrics/measurement"
 	"github.com/sbaeurle/comb/metrics/outputs"
 )
 
@@ -13,23 +15,30 @@ type Generic struct {
 	log     config.Logger
 	mu      sync.Mutex
 	cfg     config.EndpointConfig
-	input   chan []byte
-	storage map[string][]float64
+	input   chan measurement.Measurement
+	storage *storage
+	derived *expr.Derivation
+	// where drops measurements for which it is false (FILTER), keep selects
+	// the fields which are passed on (TRANSFORM).
+	where   *expr.Expr
+	keep    []string
+	next    outputs.Output
 	outputz []outputs.Output
 }
 
 func init() {
 	Modules["GENERIC"] = NewGeneric
+	Validators["GENERIC"] = validateFields
 }
 
-func NewGeneric(log config.Logger, cfg config.EndpointConfig, input chan []byte) Module {
-	return &Generic{log: log, cfg: cfg, input: input}
+func NewGeneric(log config.Logger, cfg config.EndpointConfig, input chan measurement.Measurement) Module {
+	return &Generic{log: log, cfg: cfg, input: input, storage: newStorage(cfg.GroupBy, cfg.FieldTypes), derived: compileDerived(log, cfg)}
 }
 
 func (g *Generic) StartMeasurement(path string) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	g.storage = make(map[string][]float64)
+	g.storage = newStorage(g.cfg.GroupBy, g.cfg.FieldTypes)
 
 	g.outputz = make([]outputs.Output, 0)
 	for _, v := range g.cfg.Outputs {
@@ -40,26 +49,43 @@ func (g *Generic) StartMeasurement(path string) error {
 		}
 		g.outputz = append(g.outputz, tmp)
 	}
+	if g.next != nil {
+		g.outputz = append(g.outputz, g.next)
+	}
 	return nil
 }
 
+func (g *Generic) forward(next outputs.Output) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.next = next
+}
+
 func (g *Generic) AddMeasurements() {
 	for v := range g.input {
 		var r results
-		err := json.Unmarshal(v, &r)
+		err := json.Unmarshal(v.Body, &r)
 		if err != nil {
 			g.log.Error(err)
 			continue
 		}
+		g.derived.Apply(r)
+		if g.where != nil {
+			keep, err := g.where.Eval(r)
+			if err != nil || keep == 0 {
+				continue
+			}
+		}
+		if g.keep != nil {
+			r = selectFields(r, g.keep)
+		}
 
 		g.mu.Lock()
-		for k, v := range r {
-			g.storage[k] = append(g.storage[k], v)
-		}
+		g.storage.add(v.Source, v.Received, r)
 		g.mu.Unlock()
 
-		for _, v := range g.outputz {
-			v.WriteResult(r)
+		for _, out := range g.outputz {
+			out.WriteResult(v.Source, v.Received, r)
 		}
 	}
 }
@@ -68,13 +94,5 @@ func (g *Generic) CollectMetrics() (map[string]float64, error) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	out := make(map[string]float64)
-
-	for k, v := range g.storage {
-		tmp := calculateAggregations(v, k, g.cfg.Metrics[k])
-		for m, a := range tmp {
-			out[m] = a
-		}
-	}
-	return out, nil
+	return g.storage.aggregate(g.cfg.Metrics), nil
 }
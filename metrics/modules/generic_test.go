@@ -0,0 +1,69 @@
+package modules
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sbaeurle/comb/metrics/config"
+	mock_config "github.com/sbaeurle/comb/metrics/config/mocks"
+	"github.com/sbaeurle/comb/metrics/measurement"
+	"github.com/sbaeurle/comb/metrics/outputs"
+	mock_outputs "github.com/sbaeurle/comb/metrics/outputs/mocks"
+)
+
+func TestGenericDerived(t *testing.T) {
+	type testCase struct {
+		body    []byte
+		output  map[string]float64
+		metrics map[string]float64
+	}
+	tests := map[string]testCase{
+		"derived": {
+			body:    []byte(`{"processing-time": 20, "encoding-time": 5, "objects": 4}`),
+			output:  map[string]float64{"processing-time": 20, "encoding-time": 5, "objects": 4, "total-time": 25, "time-per-object": 6.25},
+			metrics: map[string]float64{"total-time-AVG": 25},
+		},
+		"missing field": {
+			body:    []byte(`{"processing-time": 20, "objects": 0}`),
+			output:  map[string]float64{"processing-time": 20, "objects": 0},
+			metrics: map[string]float64{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := config.EndpointConfig{
+				Metrics: map[string][]string{"total-time": {"AVG"}},
+				Derived: map[string]string{
+					"total-time":      "processing-time + encoding-time",
+					"time-per-object": "total-time / objects",
+				},
+			}
+			input := make(chan measurement.Measurement, 10)
+
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockLogger := mock_config.NewMockLogger(mockCtrl)
+			mockOutput := mock_outputs.NewMockOutput(mockCtrl)
+			mockOutput.EXPECT().WriteResult(gomock.Any(), gomock.Any(), tc.output).Return(nil).Times(1)
+
+			g := NewGeneric(mockLogger, cfg, input).(*Generic)
+			g.outputz = []outputs.Output{mockOutput}
+			go g.AddMeasurements()
+
+			input <- measurement.Measurement{Body: tc.body}
+			time.Sleep(time.Millisecond * 100)
+
+			out, err := g.CollectMetrics()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tc.metrics, out) {
+				t.Fatalf("expected: %v, got: %v", tc.metrics, out)
+			}
+		})
+	}
+}
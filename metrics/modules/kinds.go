@@ -0,0 +1,216 @@
+package modules
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sbaeurle/comb/metrics/config"
+)
+
+// Kinds of fields, see config.FieldType.
+const (
+	KindSample  = "sample"
+	KindGauge   = "gauge"
+	KindCounter = "counter"
+)
+
+// Kinds lists the kinds of fields.
+var Kinds = []string{KindSample, KindGauge, KindCounter}
+
+// CounterAggregations lists the aggregations available for counters: the
+// total increase, its rate per second and the number of detected resets.
+var CounterAggregations = []string{"TOTAL", "RATE", "RESETS"}
+
+// series holds the values of a gauge or counter of a single source in the
+// order they were received.
+type series struct {
+	times  []time.Time
+	values []float64
+}
+
+func (s *series) add(t time.Time, v float64) {
+	s.times = append(s.times, t)
+	s.values = append(s.values, v)
+}
+
+// at interpolates the value of the series at time t. Before the first and
+// after the last value the series keeps that value.
+func (s *series) at(t time.Time) float64 {
+	i := sort.Search(len(s.times), func(i int) bool { return !s.times[i].Before(t) })
+	switch {
+	case i == 0:
+		return s.values[0]
+	case i == len(s.times):
+		return s.values[len(s.values)-1]
+	}
+	d := s.times[i].Sub(s.times[i-1])
+	if d <= 0 {
+		return s.values[i]
+	}
+	f := float64(t.Sub(s.times[i-1])) / float64(d)
+	return s.values[i-1] + f*(s.values[i]-s.values[i-1])
+}
+
+// aggregateGauge calculates time-weighted aggregations of gauges, each value
+// holds until the next value of its source. The last value of a source is
+// left out of AVG and P50, as the time it holds is unknown. MIN and MAX are
+// the extreme values. Without elapsed time the values are aggregated like
+// samples.
+func aggregateGauge(all []*series, metric string, aggregations []string) map[string]float64 {
+	type level struct {
+		value    float64
+		duration float64
+	}
+	var levels []level
+	var values []float64
+	total := 0.0
+	for _, s := range all {
+		values = append(values, s.values...)
+		for i := 0; i+1 < len(s.values); i++ {
+			d := s.times[i+1].Sub(s.times[i]).Seconds()
+			levels = append(levels, level{value: s.values[i], duration: d})
+			total += d
+		}
+	}
+	if total == 0 {
+		return calculateAggregations(values, metric, aggregations)
+	}
+
+	tmp := make(map[string]float64)
+	for _, agg := range aggregations {
+		out := 0.0
+		switch agg {
+		case "MIN":
+			out = math.MaxFloat64
+			for _, v := range values {
+				out = math.Min(out, v)
+			}
+		case "MAX":
+			out = -math.MaxFloat64
+			for _, v := range values {
+				out = math.Max(out, v)
+			}
+		case "AVG":
+			for _, l := range levels {
+				out += l.value * l.duration
+			}
+			out /= total
+		case "P50":
+			// The level held for at least half of the time.
+			sort.Slice(levels, func(i, j int) bool { return levels[i].value < levels[j].value })
+			elapsed := 0.0
+			for _, l := range levels {
+				elapsed += l.duration
+				out = l.value
+				if elapsed >= total/2 {
+					break
+				}
+			}
+		default:
+			continue
+		}
+		tmp[fmt.Sprintf("%s-%s", metric, agg)] = out
+	}
+	return tmp
+}
+
+// aggregateCounter calculates the increase of counters. A decreasing value
+// is taken as a reset of the counter to 0. The rate divides the increase of
+// all sources by the time between their first and last value.
+func aggregateCounter(all []*series, metric string, aggregations []string) map[string]float64 {
+	tmp := make(map[string]float64)
+	total, resets := 0.0, 0
+	var first, last time.Time
+	for _, s := range all {
+		if len(s.values) == 0 {
+			continue
+		}
+		for i := 1; i < len(s.values); i++ {
+			delta := s.values[i] - s.values[i-1]
+			if delta < 0 {
+				resets++
+				delta = s.values[i]
+			}
+			total += delta
+		}
+		if first.IsZero() || s.times[0].Before(first) {
+			first = s.times[0]
+		}
+		if t := s.times[len(s.times)-1]; t.After(last) {
+			last = t
+		}
+	}
+	if first.IsZero() {
+		return tmp
+	}
+
+	for _, agg := range aggregations {
+		switch agg {
+		case "TOTAL":
+			tmp[fmt.Sprintf("%s-%s", metric, agg)] = total
+		case "RESETS":
+			tmp[fmt.Sprintf("%s-%s", metric, agg)] = float64(resets)
+		case "RATE":
+			// The rate of a single value is undefined.
+			if elapsed := last.Sub(first).Seconds(); elapsed > 0 {
+				tmp[fmt.Sprintf("%s-%s", metric, agg)] = total / elapsed
+			}
+		}
+	}
+	return tmp
+}
+
+// Units returns the units of the metrics collected by an endpoint, metrics
+// of fields without unit and counts like "-OUTLIERS" are left out.
+func Units(cfg config.EndpointConfig, metrics map[string]float64) map[string]string {
+	types := make(map[string]config.FieldType)
+	units := make(map[string]string)
+	for f, t := range cfg.FieldTypes {
+		types[f] = t
+	}
+	for m, u := range ModuleUnits[cfg.Module] {
+		units[m] = u
+	}
+	for _, s := range cfg.Stages {
+		for f, t := range s.FieldTypes {
+			types[f] = t
+		}
+		for m, u := range ModuleUnits[s.Module] {
+			units[m] = u
+		}
+	}
+
+	out := make(map[string]string)
+	for name := range metrics {
+		// Strip the source group, e.g. "node=jetson/".
+		metric := name
+		if i := strings.LastIndex(metric, "/"); i >= 0 {
+			metric = metric[i+1:]
+		}
+		if unit, ok := units[metric]; ok {
+			out[name] = unit
+			continue
+		}
+		i := strings.LastIndex(metric, "-")
+		if i < 0 {
+			continue
+		}
+		unit := types[metric[:i]].Unit
+		switch metric[i+1:] {
+		case "OUTLIERS", "RESETS":
+			continue
+		case "RATE":
+			if unit == "" {
+				unit = "1"
+			}
+			unit += "/s"
+		}
+		if unit != "" {
+			out[name] = unit
+		}
+	}
+	return out
+}
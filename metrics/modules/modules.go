@@ -3,8 +3,12 @@ package modules
 import (
 	"fmt"
 	"math"
+	"os"
+	"sort"
 
 	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/expr"
+	"github.com/sbaeurle/comb/metrics/measurement"
 )
 
 type results map[string]float64
@@ -15,10 +19,108 @@ type Module interface {
 	CollectMetrics() (map[string]float64, error)
 }
 
-var Modules map[string]func(config.Logger, config.EndpointConfig, chan []byte) Module = make(map[string]func(config.Logger, config.EndpointConfig, chan []byte) Module)
+var Modules map[string]func(config.Logger, config.EndpointConfig, chan measurement.Measurement) Module = make(map[string]func(config.Logger, config.EndpointConfig, chan measurement.Measurement) Module)
 
+// Aggregations lists the aggregations available for metrics.
+var Aggregations = []string{"MIN", "MAX", "AVG", "P50"}
+
+// Joiner is implemented by modules whose metrics depend on the results of
+// other endpoints. Join receives the metrics collected from all endpoints
+// and their number of samples, the returned metrics are added to those of
+// the module.
+type Joiner interface {
+	Join(collected map[string]map[string]float64, samples map[string]int) map[string]float64
+}
+
+// Snapshotter is implemented by modules which cannot aggregate their metrics
+// while the run continues. Snapshots leave out modules whose Snapshots
+// returns false.
+type Snapshotter interface {
+	Snapshots() bool
+}
+
+// ModuleUnits holds the units of the metrics modules report on their own,
+// independent of the fields of the endpoint.
+var ModuleUnits map[string]map[string]string = make(map[string]map[string]string)
+
+// Validators check the module specific settings of an endpoint. Paths of the
+// problems are relative to the endpoint.
+var Validators map[string]func(config.EndpointConfig) []config.Problem = make(map[string]func(config.EndpointConfig) []config.Problem)
+
+// compileDerived compiles the derived fields of an endpoint. Invalid
+// definitions are rejected by the validation, modules skip them.
+func compileDerived(log config.Logger, cfg config.EndpointConfig) *expr.Derivation {
+	d, err := expr.Compile(cfg.Derived)
+	if err != nil {
+		log.Errorf("%s: derived fields disabled: %v", cfg.Name, err)
+		return nil
+	}
+	return d
+}
+
+// validateFields checks that the aggregated metrics of an endpoint are
+// received or derived fields.
+func validateFields(cfg config.EndpointConfig) []config.Problem {
+	fields := make(map[string]bool)
+	for _, f := range cfg.Fields {
+		fields[f] = true
+	}
+	for f := range cfg.Derived {
+		fields[f] = true
+	}
+
+	var problems []config.Problem
+	for _, m := range config.SortedMetrics(cfg.Metrics) {
+		if !fields[m] {
+			problems = append(problems, config.Problem{Path: "Metrics." + m, Message: fmt.Sprintf("metric %s is not in Fields", m)})
+		}
+	}
+	return problems
+}
+
+// validateFile checks that the module setting key names an existing file.
+func validateFile(cfg config.EndpointConfig, key string) []config.Problem {
+	path := "Config." + key
+	if cfg.Config[key] == "" {
+		return []config.Problem{{Path: path, Message: "missing"}}
+	}
+	info, err := os.Stat(cfg.Config[key])
+	if err != nil {
+		return []config.Problem{{Path: path, Message: err.Error()}}
+	}
+	if info.IsDir() {
+		return []config.Problem{{Path: path, Message: fmt.Sprintf("%s is a directory", cfg.Config[key])}}
+	}
+	return nil
+}
+
+// selectFields returns the given fields of r.
+func selectFields(r results, fields []string) results {
+	out := make(results, len(fields))
+	for _, f := range fields {
+		if v, ok := r[f]; ok {
+			out[f] = v
+		}
+	}
+	return out
+}
+
+// calculateAggregations aggregates the values of a metric. If an outlier
+// policy is among the aggregations, the outliers are removed first.
 func calculateAggregations(values []float64, metric string, aggregations []string) map[string]float64 {
 	tmp := make(map[string]float64)
+	// Aggregations of no values are undefined, e.g. AVG would be NaN.
+	if len(values) == 0 {
+		return tmp
+	}
+	if p := outlierPolicy(aggregations); p != nil {
+		cleaned := p.clean(values)
+		tmp[fmt.Sprintf("%s-OUTLIERS", metric)] = float64(len(values) - len(cleaned))
+		values = cleaned
+		if len(values) == 0 {
+			return tmp
+		}
+	}
 	for _, agg := range aggregations {
 		out := 0.0
 		switch agg {
@@ -38,8 +140,13 @@ func calculateAggregations(values []float64, metric string, aggregations []strin
 			}
 			out /= float64(len(values))
 		case "P50":
-			n := int(50.0 / 100.0 * float64(len(values)))
-			out = values[n]
+			// Sort a copy, the values keep growing after mid-run snapshots.
+			sorted := append([]float64(nil), values...)
+			sort.Float64s(sorted)
+			n := int(50.0 / 100.0 * float64(len(sorted)))
+			out = sorted[n]
+		default:
+			continue
 		}
 		tmp[fmt.Sprintf("%s-%s", metric, agg)] = out
 	}
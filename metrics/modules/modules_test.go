@@ -34,15 +34,73 @@ func TestCalculateAggregations(t *testing.T) {
 				"test-P50": 4.0,
 			},
 		},
+		"unsorted": {
+			values:       []float64{9.0, 1.0, 8.0, 2.0, 4.0, 3.0},
+			metric:       "test",
+			aggregations: []string{"P50"},
+			out: map[string]float64{
+				"test-P50": 4.0,
+			},
+		},
+		"iqr": {
+			values:       []float64{10, 11, 12, 12, 13, 14, 15, 90},
+			metric:       "test",
+			aggregations: []string{"MAX", "AVG", "IQR"},
+			out: map[string]float64{
+				"test-MAX":      15.0,
+				"test-AVG":      12.4285714285714285,
+				"test-OUTLIERS": 1.0,
+			},
+		},
+		"mad": {
+			values:       []float64{10, 11, 12, 12, 13, 14, 15, 90, -50},
+			metric:       "test",
+			aggregations: []string{"MIN", "MAX", "MAD:3.5"},
+			out: map[string]float64{
+				"test-MIN":      10.0,
+				"test-MAX":      15.0,
+				"test-OUTLIERS": 2.0,
+			},
+		},
+		"mad constant": {
+			values:       []float64{5, 5, 5, 5, 9},
+			metric:       "test",
+			aggregations: []string{"MAX", "MAD"},
+			out: map[string]float64{
+				"test-MAX":      9.0,
+				"test-OUTLIERS": 0.0,
+			},
+		},
+		"trim": {
+			values:       []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 100},
+			metric:       "test",
+			aggregations: []string{"MIN", "MAX", "TRIM:10"},
+			out: map[string]float64{
+				"test-MIN":      2.0,
+				"test-MAX":      9.0,
+				"test-OUTLIERS": 2.0,
+			},
+		},
+		"empty": {
+			values:       []float64{},
+			metric:       "test",
+			aggregations: []string{"MIN", "AVG", "P50"},
+			out:          map[string]float64{},
+		},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
+			values := make([]float64, len(tc.values))
+			copy(values, tc.values)
 			out := calculateAggregations(tc.values, tc.metric, tc.aggregations)
 
 			if !reflect.DeepEqual(tc.out, out) {
 				t.Fatalf("expected: %v, got: %v", tc.out, out)
 			}
+			if !reflect.DeepEqual(values, tc.values) {
+				t.Fatalf("expected: %v, got: %v", values, tc.values)
+			}
 		})
 	}
 }
@@ -3,7 +3,9 @@ package modules
 import (
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -11,6 +13,8 @@ import (
 	"sync"
 
 	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/expr"
+	"github.com/sbaeurle/comb/metrics/measurement"
 	"github.com/sbaeurle/comb/metrics/outputs"
 )
 
@@ -19,7 +23,9 @@ type MOT struct {
 	log     config.Logger
 	cfg     config.EndpointConfig
 	path    string
-	input   chan []byte
+	input   chan measurement.Measurement
+	derived *expr.Derivation
+	next    outputs.Output
 	outputz []outputs.Output
 }
 
@@ -40,13 +46,35 @@ type detection struct {
 
 func init() {
 	Modules["MOT"] = NewMOT
+	Validators["MOT"] = validateMOT
 }
 
-func NewMOT(log config.Logger, cfg config.EndpointConfig, input chan []byte) Module {
+// validateMOT checks the settings passed to TrackEval. Metrics are read from
+// its summary and need not be received fields.
+func validateMOT(cfg config.EndpointConfig) []config.Problem {
+	problems := validateFile(cfg, "MotScript")
+	if _, err := os.Stat(cfg.Config["MotScript"]); cfg.Config["MotScript"] != "" && errors.Is(err, fs.ErrNotExist) {
+		// TrackEval is installed separately, the endpoint fails to evaluate
+		// runs until it is.
+		problems[0].Warning = true
+	}
+	for _, key := range []string{"Benchmark", "SplitToEval", "SeqInfo", "GTFolder"} {
+		if cfg.Config[key] == "" {
+			problems = append(problems, config.Problem{Path: "Config." + key, Message: "missing"})
+		}
+	}
+	if len(cfg.Outputs) == 0 {
+		problems = append(problems, config.Problem{Path: "Outputs", Message: "TrackEval requires an output file of the tracker"})
+	}
+	return problems
+}
+
+func NewMOT(log config.Logger, cfg config.EndpointConfig, input chan measurement.Measurement) Module {
 	return &MOT{
-		log:   log,
-		cfg:   cfg,
-		input: input,
+		log:     log,
+		cfg:     cfg,
+		input:   input,
+		derived: compileDerived(log, cfg),
 	}
 }
 
@@ -64,13 +92,22 @@ func (m *MOT) StartMeasurement(path string) error {
 		}
 		m.outputz = append(m.outputz, tmp)
 	}
+	if m.next != nil {
+		m.outputz = append(m.outputz, m.next)
+	}
 	return nil
 }
 
+func (m *MOT) forward(next outputs.Output) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.next = next
+}
+
 func (m *MOT) AddMeasurements() {
 	for v := range m.input {
 		var r mot
-		err := json.Unmarshal(v, &r)
+		err := json.Unmarshal(v.Body, &r)
 		if err != nil {
 			m.log.Error(err)
 			continue
@@ -80,13 +117,20 @@ func (m *MOT) AddMeasurements() {
 		for _, det := range r.Detections {
 			for _, out := range m.outputz {
 				tmp := map[string]float64{"frame-number": float64(r.Count), "id": float64(det.ID), "bb_left": float64(det.BB_left), "bb_top": float64(det.BB_top), "bb_width": float64(det.BB_width), "bb_height": float64(det.BB_height), "conf": det.Conf, "x": -1.0, "y": -1.0, "z": -1.0}
-				out.WriteResult(tmp)
+				m.derived.Apply(tmp)
+				out.WriteResult(v.Source, v.Received, tmp)
 			}
 		}
 		m.mu.Unlock()
 	}
 }
 
+// Snapshots is false, TrackEval scores the tracks against the ground truth
+// of the whole sequence and overwrites its summary in the run folder.
+func (m *MOT) Snapshots() bool {
+	return false
+}
+
 func (m *MOT) CollectMetrics() (map[string]float64, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -9,6 +9,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/sbaeurle/comb/metrics/config"
 	mock_config "github.com/sbaeurle/comb/metrics/config/mocks"
+	"github.com/sbaeurle/comb/metrics/measurement"
 	"github.com/sbaeurle/comb/metrics/outputs"
 	mock_outputs "github.com/sbaeurle/comb/metrics/outputs/mocks"
 )
@@ -42,7 +43,7 @@ func TestMOTAddMeasurements(t *testing.T) {
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			cfg := config.EndpointConfig{}
-			input := make(chan []byte, 10)
+			input := make(chan measurement.Measurement, 10)
 
 			mockCtrl := gomock.NewController(t)
 			defer mockCtrl.Finish()
@@ -51,13 +52,13 @@ func TestMOTAddMeasurements(t *testing.T) {
 			mockLogger.EXPECT().Error(gomock.Any()).MaxTimes(0)
 
 			mockOutput := mock_outputs.NewMockOutput(mockCtrl)
-			mockOutput.EXPECT().WriteResult(tc.output).Return(nil).Times(1)
+			mockOutput.EXPECT().WriteResult(gomock.Any(), gomock.Any(), tc.output).Return(nil).Times(1)
 
 			scr := MOT{log: mockLogger, cfg: cfg, input: input, outputz: []outputs.Output{mockOutput}}
 
 			go scr.AddMeasurements()
 
-			input <- tc.body
+			input <- measurement.Measurement{Body: tc.body}
 			time.Sleep(time.Millisecond * 100)
 		})
 	}
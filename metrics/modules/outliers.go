@@ -0,0 +1,126 @@
+package modules
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Outlier policies which may be listed among the aggregations of a metric,
+// optionally with a parameter, e.g. "IQR:3". Aggregations are then computed
+// without the outliers and their number is reported as "<metric>-OUTLIERS".
+const (
+	// OutlierIQR removes values outside the fences Q1 - k*IQR and
+	// Q3 + k*IQR, k defaults to 1.5.
+	OutlierIQR = "IQR"
+	// OutlierMAD removes values whose modified z-score, based on the median
+	// absolute deviation, exceeds z, which defaults to 3.5.
+	OutlierMAD = "MAD"
+	// OutlierTrim removes the given percentage of the lowest and of the
+	// highest values, e.g. "TRIM:5".
+	OutlierTrim = "TRIM"
+)
+
+// OutlierPolicy removes outliers from the values of a metric.
+type OutlierPolicy struct {
+	Name  string
+	Param float64
+}
+
+// ParseOutlierPolicy parses an entry of the aggregations of a metric. ok is
+// false if s is no outlier policy.
+func ParseOutlierPolicy(s string) (p OutlierPolicy, ok bool, err error) {
+	parts := strings.SplitN(s, ":", 2)
+	p.Name = parts[0]
+	switch p.Name {
+	case OutlierIQR:
+		p.Param = 1.5
+	case OutlierMAD:
+		p.Param = 3.5
+	case OutlierTrim:
+		if len(parts) == 1 {
+			return p, true, fmt.Errorf("%s requires a percentage, e.g. %s:5", p.Name, p.Name)
+		}
+	default:
+		return p, false, nil
+	}
+
+	if len(parts) == 2 {
+		p.Param, err = strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return p, true, fmt.Errorf("%s: invalid parameter %s", p.Name, parts[1])
+		}
+	}
+	if p.Name == OutlierTrim && (p.Param < 0 || p.Param >= 50) {
+		return p, true, fmt.Errorf("%s: percentage must be between 0 and 50", p.Name)
+	}
+	if p.Param < 0 || math.IsNaN(p.Param) || math.IsInf(p.Param, 0) {
+		return p, true, fmt.Errorf("%s: parameter must not be negative", p.Name)
+	}
+	return p, true, nil
+}
+
+// outlierPolicy returns the outlier policy among the aggregations of a
+// metric, or nil. Invalid policies are rejected by the validation and ignored.
+func outlierPolicy(aggregations []string) *OutlierPolicy {
+	for _, a := range aggregations {
+		p, ok, err := ParseOutlierPolicy(a)
+		if ok && err == nil {
+			return &p
+		}
+	}
+	return nil
+}
+
+// clean returns the values without outliers in ascending order.
+func (p OutlierPolicy) clean(values []float64) []float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	var low, high float64
+	switch p.Name {
+	case OutlierIQR:
+		q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+		low, high = q1-p.Param*(q3-q1), q3+p.Param*(q3-q1)
+	case OutlierMAD:
+		median := quantile(sorted, 0.5)
+		deviations := make([]float64, len(sorted))
+		for i, v := range sorted {
+			deviations[i] = math.Abs(v - median)
+		}
+		sort.Float64s(deviations)
+		mad := quantile(deviations, 0.5)
+		// Without deviation, e.g. for constant values, nothing is an outlier.
+		if mad == 0 {
+			return sorted
+		}
+		// The modified z-score is 0.6745 * (v - median) / MAD.
+		low, high = median-p.Param*mad/0.6745, median+p.Param*mad/0.6745
+	case OutlierTrim:
+		n := int(float64(len(sorted)) * p.Param / 100)
+		return sorted[n : len(sorted)-n]
+	}
+
+	out := make([]float64, 0, len(sorted))
+	for _, v := range sorted {
+		if v >= low && v <= high {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// quantile interpolates the q-quantile of sorted values.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
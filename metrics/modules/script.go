@@ -9,6 +9,8 @@ import (
 
 	"github.com/d5/tengo/v2"
 	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/expr"
+	"github.com/sbaeurle/comb/metrics/measurement"
 	"github.com/sbaeurle/comb/metrics/outputs"
 )
 
@@ -16,23 +18,30 @@ type Script struct {
 	mu      sync.Mutex
 	log     config.Logger
 	cfg     config.EndpointConfig
-	input   chan []byte
-	storage map[string][]float64
+	input   chan measurement.Measurement
+	storage *storage
+	derived *expr.Derivation
+	next    outputs.Output
 	outputz []outputs.Output
 }
 
 func init() {
 	Modules["SCRIPT"] = NewScript
+	Validators["SCRIPT"] = validateScript
 }
 
-func NewScript(log config.Logger, cfg config.EndpointConfig, input chan []byte) Module {
-	return &Script{log: log, cfg: cfg, input: input}
+func validateScript(cfg config.EndpointConfig) []config.Problem {
+	return append(validateFile(cfg, "ScriptPath"), validateFields(cfg)...)
+}
+
+func NewScript(log config.Logger, cfg config.EndpointConfig, input chan measurement.Measurement) Module {
+	return &Script{log: log, cfg: cfg, input: input, storage: newStorage(cfg.GroupBy, cfg.FieldTypes), derived: compileDerived(log, cfg)}
 }
 
 func (s *Script) StartMeasurement(path string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.storage = make(map[string][]float64)
+	s.storage = newStorage(s.cfg.GroupBy, s.cfg.FieldTypes)
 
 	s.outputz = make([]outputs.Output, 0)
 	for _, v := range s.cfg.Outputs {
@@ -43,13 +52,22 @@ func (s *Script) StartMeasurement(path string) error {
 		}
 		s.outputz = append(s.outputz, tmp)
 	}
+	if s.next != nil {
+		s.outputz = append(s.outputz, s.next)
+	}
 	return nil
 }
 
+func (s *Script) forward(next outputs.Output) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next = next
+}
+
 func (s *Script) AddMeasurements() {
 	for v := range s.input {
 		var r map[string]interface{}
-		err := json.Unmarshal(v, &r)
+		err := json.Unmarshal(v.Body, &r)
 		if err != nil {
 			s.log.Error(err)
 			continue
@@ -84,9 +102,14 @@ func (s *Script) AddMeasurements() {
 				output[key] = value
 			}
 		}
+		s.derived.Apply(output)
+
+		s.mu.Lock()
+		s.storage.add(v.Source, v.Received, output)
+		s.mu.Unlock()
 
 		for _, out := range s.outputz {
-			out.WriteResult(output)
+			out.WriteResult(v.Source, v.Received, output)
 		}
 
 	}
@@ -96,13 +119,5 @@ func (s *Script) CollectMetrics() (map[string]float64, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	out := make(map[string]float64)
-
-	for k, v := range s.storage {
-		tmp := calculateAggregations(v, k, s.cfg.Metrics[k])
-		for m, a := range tmp {
-			out[m] = a
-		}
-	}
-	return out, nil
+	return s.storage.aggregate(s.cfg.Metrics), nil
 }
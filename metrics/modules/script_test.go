@@ -8,6 +8,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/sbaeurle/comb/metrics/config"
 	mock_config "github.com/sbaeurle/comb/metrics/config/mocks"
+	"github.com/sbaeurle/comb/metrics/measurement"
 	"github.com/sbaeurle/comb/metrics/outputs"
 	mock_outputs "github.com/sbaeurle/comb/metrics/outputs/mocks"
 )
@@ -62,7 +63,7 @@ func TestScriptModule(t *testing.T) {
 					"ScriptPath": path + "/test.tengo",
 				},
 			}
-			input := make(chan []byte, 10)
+			input := make(chan measurement.Measurement, 10)
 			os.WriteFile(cfg.Config["ScriptPath"], tc.script, 0755)
 			defer os.Remove(cfg.Config["ScriptPath"])
 
@@ -74,14 +75,14 @@ func TestScriptModule(t *testing.T) {
 
 			mockOutput := mock_outputs.NewMockOutput(mockCtrl)
 			if tc.output != nil {
-				mockOutput.EXPECT().WriteResult(tc.output).Return(nil).Times(1)
+				mockOutput.EXPECT().WriteResult(gomock.Any(), gomock.Any(), tc.output).Return(nil).Times(1)
 			}
 
-			scr := Script{log: mockLogger, cfg: cfg, input: input, outputz: []outputs.Output{mockOutput}}
+			scr := Script{log: mockLogger, cfg: cfg, input: input, storage: newStorage(nil, nil), outputz: []outputs.Output{mockOutput}}
 
 			go scr.AddMeasurements()
 
-			input <- tc.body
+			input <- measurement.Measurement{Body: tc.body}
 			time.Sleep(time.Millisecond * 100)
 		})
 	}
@@ -0,0 +1,108 @@
+package modules
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/measurement"
+)
+
+// storage keeps the received values per field. If source labels are
+// configured to group by, values are additionally kept per source group.
+// Gauges and counters are kept as series per source with the time their
+// values were received.
+type storage struct {
+	groupBy []string
+	types   map[string]config.FieldType
+	values  map[string][]float64
+	groups  map[string]map[string][]float64
+	series  map[string]map[measurement.Source]*series
+}
+
+func newStorage(groupBy []string, types map[string]config.FieldType) *storage {
+	return &storage{
+		groupBy: groupBy,
+		types:   types,
+		values:  make(map[string][]float64),
+		groups:  make(map[string]map[string][]float64),
+		series:  make(map[string]map[measurement.Source]*series),
+	}
+}
+
+func (s *storage) add(src measurement.Source, received time.Time, r map[string]float64) {
+	var group map[string][]float64
+	if len(s.groupBy) > 0 {
+		key := src.Group(s.groupBy)
+		group = s.groups[key]
+		if group == nil {
+			group = make(map[string][]float64)
+			s.groups[key] = group
+		}
+	}
+
+	for k, v := range r {
+		switch s.types[k].Kind {
+		case KindGauge, KindCounter:
+			if s.series[k] == nil {
+				s.series[k] = make(map[measurement.Source]*series)
+			}
+			if s.series[k][src] == nil {
+				s.series[k][src] = &series{}
+			}
+			s.series[k][src].add(received, v)
+			continue
+		}
+
+		s.values[k] = append(s.values[k], v)
+		if group != nil {
+			group[k] = append(group[k], v)
+		}
+	}
+}
+
+// aggregate calculates the configured metrics over all values. Metrics per
+// source group are prefixed with the group, e.g. "node=jetson/processing-time-AVG".
+func (s *storage) aggregate(metrics map[string][]string) map[string]float64 {
+	out := make(map[string]float64)
+	for k, v := range s.values {
+		for m, a := range calculateAggregations(v, k, metrics[k]) {
+			out[m] = a
+		}
+	}
+
+	for g, values := range s.groups {
+		for k, v := range values {
+			for m, a := range calculateAggregations(v, k, metrics[k]) {
+				out[fmt.Sprintf("%s/%s", g, m)] = a
+			}
+		}
+	}
+
+	for k, sources := range s.series {
+		aggregate := aggregateGauge
+		if s.types[k].Kind == KindCounter {
+			aggregate = aggregateCounter
+		}
+
+		var all []*series
+		groups := make(map[string][]*series)
+		for src, ser := range sources {
+			all = append(all, ser)
+			if len(s.groupBy) > 0 {
+				key := src.Group(s.groupBy)
+				groups[key] = append(groups[key], ser)
+			}
+		}
+
+		for m, a := range aggregate(all, k, metrics[k]) {
+			out[m] = a
+		}
+		for g, group := range groups {
+			for m, a := range aggregate(group, k, metrics[k]) {
+				out[fmt.Sprintf("%s/%s", g, m)] = a
+			}
+		}
+	}
+	return out
+}
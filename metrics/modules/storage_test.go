@@ -0,0 +1,137 @@
+package modules
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/measurement"
+)
+
+func TestStorageAggregate(t *testing.T) {
+	type sample struct {
+		src    measurement.Source
+		at     time.Duration
+		values map[string]float64
+	}
+	type testCase struct {
+		groupBy      []string
+		kind         string
+		aggregations []string
+		samples      []sample
+		out          map[string]float64
+	}
+	tests := map[string]testCase{
+		"ungrouped": {
+			samples: []sample{
+				{src: measurement.Source{Node: "a"}, values: map[string]float64{"test": 1.0}},
+				{src: measurement.Source{Node: "b"}, values: map[string]float64{"test": 3.0}},
+			},
+			out: map[string]float64{
+				"test-AVG": 2.0,
+			},
+		},
+		"by-node": {
+			groupBy: []string{"node"},
+			samples: []sample{
+				{src: measurement.Source{Node: "a"}, values: map[string]float64{"test": 1.0}},
+				{src: measurement.Source{Node: "b"}, values: map[string]float64{"test": 3.0}},
+				{src: measurement.Source{Node: "b"}, values: map[string]float64{"test": 5.0}},
+			},
+			out: map[string]float64{
+				"test-AVG":        3.0,
+				"node=a/test-AVG": 1.0,
+				"node=b/test-AVG": 4.0,
+			},
+		},
+		"gauge": {
+			kind:         KindGauge,
+			aggregations: []string{"MIN", "MAX", "AVG", "P50"},
+			samples: []sample{
+				{at: 0, values: map[string]float64{"test": 2.0}},
+				{at: 8 * time.Second, values: map[string]float64{"test": 10.0}},
+				{at: 10 * time.Second, values: map[string]float64{"test": 0.0}},
+			},
+			out: map[string]float64{
+				"test-MIN": 0.0,
+				"test-MAX": 10.0,
+				"test-AVG": 3.6,
+				"test-P50": 2.0,
+			},
+		},
+		"gauge without time": {
+			kind: KindGauge,
+			samples: []sample{
+				{values: map[string]float64{"test": 2.0}},
+				{values: map[string]float64{"test": 4.0}},
+			},
+			out: map[string]float64{
+				"test-AVG": 3.0,
+			},
+		},
+		"gauge by-node": {
+			groupBy: []string{"node"},
+			kind:    KindGauge,
+			samples: []sample{
+				{src: measurement.Source{Node: "a"}, at: 0, values: map[string]float64{"test": 1.0}},
+				{src: measurement.Source{Node: "b"}, at: 0, values: map[string]float64{"test": 4.0}},
+				{src: measurement.Source{Node: "a"}, at: 3 * time.Second, values: map[string]float64{"test": 2.0}},
+				{src: measurement.Source{Node: "b"}, at: time.Second, values: map[string]float64{"test": 6.0}},
+			},
+			out: map[string]float64{
+				"test-AVG":        1.75,
+				"node=a/test-AVG": 1.0,
+				"node=b/test-AVG": 4.0,
+			},
+		},
+		"counter": {
+			kind:         KindCounter,
+			aggregations: []string{"TOTAL", "RATE", "RESETS"},
+			samples: []sample{
+				{src: measurement.Source{Node: "a"}, at: 0, values: map[string]float64{"test": 10.0}},
+				{src: measurement.Source{Node: "b"}, at: time.Second, values: map[string]float64{"test": 0.0}},
+				{src: measurement.Source{Node: "a"}, at: 2 * time.Second, values: map[string]float64{"test": 14.0}},
+				{src: measurement.Source{Node: "b"}, at: 3 * time.Second, values: map[string]float64{"test": 5.0}},
+				// Node a restarted its counter.
+				{src: measurement.Source{Node: "a"}, at: 4 * time.Second, values: map[string]float64{"test": 3.0}},
+			},
+			out: map[string]float64{
+				"test-TOTAL":  12.0,
+				"test-RATE":   3.0,
+				"test-RESETS": 1.0,
+			},
+		},
+		"counter single value": {
+			kind:         KindCounter,
+			aggregations: []string{"TOTAL", "RATE"},
+			samples: []sample{
+				{values: map[string]float64{"test": 10.0}},
+			},
+			out: map[string]float64{
+				"test-TOTAL": 0.0,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			aggregations := tc.aggregations
+			if aggregations == nil {
+				aggregations = []string{"AVG"}
+			}
+			types := map[string]config.FieldType{"test": {Kind: tc.kind}}
+
+			start := time.Now()
+			s := newStorage(tc.groupBy, types)
+			for _, v := range tc.samples {
+				s.add(v.src, start.Add(v.at), v.values)
+			}
+
+			out := s.aggregate(map[string][]string{"test": aggregations})
+			if !reflect.DeepEqual(tc.out, out) {
+				t.Fatalf("expected: %v, got: %v", tc.out, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package modules
+
+import (
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/measurement"
+)
+
+func init() {
+	Modules["TRANSFORM"] = NewTransform
+	Validators["TRANSFORM"] = validateFields
+}
+
+// NewTransform creates a module which computes the Derived fields and only
+// keeps the Fields, e.g. to rename or drop fields before the next stage of a
+// chain. Without Fields every field is kept. Results are handled like by
+// GENERIC.
+func NewTransform(log config.Logger, cfg config.EndpointConfig, input chan measurement.Measurement) Module {
+	g := NewGeneric(log, cfg, input).(*Generic)
+	if len(cfg.Fields) > 0 {
+		g.keep = cfg.Fields
+	}
+	return g
+}
@@ -4,9 +4,12 @@ import (
 	"encoding/csv"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/measurement"
 )
 
 func init() {
@@ -62,11 +65,13 @@ func NewCSVOutput(log config.Logger, filename string, filepath string, fields []
 	return &CSVOutput{log: log, w: w, filename: filename, fields: fields}, nil
 }
 
-func (c *CSVOutput) WriteResult(out map[string]float64) error {
+func (c *CSVOutput) WriteResult(src measurement.Source, received time.Time, out map[string]float64) error {
 	// TODO: Implement proper CSV writing. Maybe even use parsed structure from configuration for performance reasons
 	var tmp []string
 	for _, v := range c.fields {
-		if val, ok := out[v]; ok {
+		if strings.HasPrefix(v, measurement.FieldPrefix) {
+			tmp = append(tmp, src.Label(strings.TrimPrefix(v, measurement.FieldPrefix)))
+		} else if val, ok := out[v]; ok {
 			tmp = append(tmp, fmt.Sprintf("%v", val))
 		} else {
 			tmp = append(tmp, fmt.Sprintf("%v", 0))
@@ -6,8 +6,10 @@ package mock_outputs
 
 import (
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
+	measurement "github.com/sbaeurle/comb/metrics/measurement"
 )
 
 // MockOutput is a mock of Output interface.
@@ -48,15 +50,15 @@ func (mr *MockOutputMockRecorder) ChangePath(arg0 interface{}) *gomock.Call {
 }
 
 // WriteResult mocks base method.
-func (m *MockOutput) WriteResult(arg0 map[string]float64) error {
+func (m *MockOutput) WriteResult(arg0 measurement.Source, arg1 time.Time, arg2 map[string]float64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "WriteResult", arg0)
+	ret := m.ctrl.Call(m, "WriteResult", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // WriteResult indicates an expected call of WriteResult.
-func (mr *MockOutputMockRecorder) WriteResult(arg0 interface{}) *gomock.Call {
+func (mr *MockOutputMockRecorder) WriteResult(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteResult", reflect.TypeOf((*MockOutput)(nil).WriteResult), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteResult", reflect.TypeOf((*MockOutput)(nil).WriteResult), arg0, arg1, arg2)
 }
@@ -1,10 +1,17 @@
 //go:generate mockgen --destination mocks/mock_outputs.go github.com/sbaeurle/comb/metrics/outputs Output
 package outputs
 
-import "github.com/sbaeurle/comb/metrics/config"
+import (
+	"time"
 
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/measurement"
+)
+
+// Output writes the results of every measurement, received is the time the
+// measurement arrived at the metric service.
 type Output interface {
-	WriteResult(out map[string]float64) error
+	WriteResult(src measurement.Source, received time.Time, out map[string]float64) error
 }
 
 var Outputz map[string]func(log config.Logger, filename string, filepath string, fields []string, header bool) (Output, error) = make(map[string]func(config.Logger, string, string, []string, bool) (Output, error))
@@ -0,0 +1,372 @@
+package relay
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sbaeurle/comb/metrics/clock"
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/routes"
+)
+
+// Defaults of the relay configuration.
+const (
+	DefaultSpoolFolder = "spool"
+	DefaultBatchSize   = 500
+	DefaultInterval    = time.Second
+)
+
+// The relay synchronizes its clock with the upstream every syncEvery
+// intervals and estimates its offset from the syncExchanges last exchanges.
+const (
+	syncEvery     = 10
+	syncExchanges = 8
+)
+
+// errRejected marks batches the upstream will never accept.
+var errRejected = errors.New("batch rejected by upstream")
+
+// Relay accepts measurements on the endpoint routes, spools them and
+// forwards them to the upstream metric service.
+type Relay struct {
+	log    config.Logger
+	cfg    config.RelayConfig
+	name   string
+	spool  *Spool
+	client *http.Client
+
+	// latest and runs are the sessions of the upstream as of the last batch,
+	// exchanges the last time exchanges with the upstream.
+	mu        sync.Mutex
+	latest    string
+	runs      map[string]int
+	exchanges []clock.Exchange
+}
+
+// New opens the spool of the relay. Missing settings take their defaults.
+func New(log config.Logger, cfg config.RelayConfig) (*Relay, error) {
+	if cfg.Upstream == "" {
+		return nil, errors.New("relay requires Relay.Upstream")
+	}
+	if cfg.SpoolFolder == "" {
+		cfg.SpoolFolder = DefaultSpoolFolder
+	}
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = DefaultInterval
+	}
+	cfg.Upstream = strings.TrimSuffix(cfg.Upstream, "/")
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	spool, err := OpenSpool(cfg.SpoolFolder, cfg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	name, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return &Relay{
+		log:    log,
+		cfg:    cfg,
+		name:   name,
+		spool:  spool,
+		client: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+// RegisterRoutes accepts measurements on the routes of the endpoints and
+// the time exchange routes, unscoped and below /sessions/{session}.
+func (rl *Relay) RegisterRoutes(r *mux.Router, endpoints []config.EndpointConfig) {
+	for _, prefix := range []string{"", "/sessions/{session}"} {
+		for _, e := range endpoints {
+			r.HandleFunc(prefix+e.Url, rl.Ingest).Methods("POST")
+		}
+		r.HandleFunc(prefix+"/clock/sync", rl.Sync).Methods("POST")
+		r.HandleFunc(prefix+routes.ClockReportURL, rl.Ingest).Methods("POST")
+	}
+}
+
+// Ingest spools a measurement with the time it was received and the headers
+// describing its source. Measurements are tagged with the session and run of
+// the upstream they belong to as far as the relay knows. Measurements are
+// acknowledged once spooled, time exchanges are relayed like measurements.
+func (rl *Relay) Ingest(w http.ResponseWriter, r *http.Request) {
+	received := time.Now()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// The upstream ingests batches as JSON, so only JSON can be relayed.
+	if !json.Valid(body) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	url := r.URL.Path
+	session, ok := mux.Vars(r)["session"]
+	if ok {
+		url = strings.TrimPrefix(url, "/sessions/"+session)
+	} else {
+		session = r.Header.Get(routes.HeaderSession)
+	}
+	header := make(map[string]string)
+	for _, h := range routes.SourceHeaders {
+		if v := r.Header.Get(h); v != "" {
+			header[h] = v
+		}
+	}
+
+	rl.mu.Lock()
+	if session == "" {
+		session = rl.latest
+	}
+	run := rl.runs[session]
+	rl.mu.Unlock()
+
+	err = rl.spool.Append(routes.BatchSample{
+		Session:  session,
+		Run:      run,
+		Url:      url,
+		Received: received,
+		Address:  r.RemoteAddr,
+		Header:   header,
+		Body:     body,
+	})
+	if err != nil {
+		rl.log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// Sync answers a time request of a node with the clock of the upstream, i.e.
+// the clock of the relay shifted by its estimated offset to the upstream.
+func (rl *Relay) Sync(w http.ResponseWriter, r *http.Request) {
+	receive := time.Now()
+
+	offset, ok := rl.offset()
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var ex clock.Exchange
+	err := json.NewDecoder(r.Body).Decode(&ex)
+	if err != nil {
+		rl.log.Error(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ex.Receive = receive.Add(offset).UnixNano()
+	ex.Destination = 0
+
+	w.Header().Set("Content-Type", "application/json")
+	ex.Transmit = time.Now().Add(offset).UnixNano()
+	json.NewEncoder(w).Encode(ex)
+}
+
+// offset returns the offset of the relay clock to the upstream of the
+// exchange with the lowest delay, false if the relay never synchronized.
+func (rl *Relay) offset() (time.Duration, bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if len(rl.exchanges) == 0 {
+		return 0, false
+	}
+	best := rl.exchanges[0]
+	for _, ex := range rl.exchanges[1:] {
+		if ex.Delay() < best.Delay() {
+			best = ex
+		}
+	}
+	return best.Offset(), true
+}
+
+// syncClock exchanges the time with the upstream.
+func (rl *Relay) syncClock() error {
+	var ex clock.Exchange
+	err := rl.post("/clock/sync", clock.Exchange{Origin: time.Now().UnixNano()}, &ex)
+	if err != nil {
+		return err
+	}
+	ex.Destination = time.Now().UnixNano()
+	if ex.Delay() < 0 {
+		return fmt.Errorf("invalid time exchange %+v", ex)
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.exchanges = append(rl.exchanges, ex)
+	if len(rl.exchanges) > syncExchanges {
+		rl.exchanges = rl.exchanges[1:]
+	}
+	return nil
+}
+
+// Run forwards sealed segments until stop is closed. The open segment is
+// sealed every Interval, failed batches are retried with the next interval.
+// The clock is synchronized with the upstream every syncEvery intervals.
+func (rl *Relay) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(rl.cfg.Interval)
+	defer ticker.Stop()
+
+	down := false
+	for ticks := 0; ; {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if ticks%syncEvery == 0 {
+				err := rl.syncClock()
+				if err != nil {
+					rl.log.Debugf("Clock not synchronized with upstream %s: %v", rl.cfg.Upstream, err)
+				}
+			}
+			ticks++
+			err := rl.spool.Seal()
+			if err != nil {
+				rl.log.Error(err)
+			}
+		case <-rl.spool.Sealed():
+		}
+
+		err := rl.flush()
+		if err != nil && !down {
+			rl.log.Warnf("Upstream %s unavailable, spooling measurements: %v", rl.cfg.Upstream, err)
+		} else if err == nil && down {
+			rl.log.Infof("Upstream %s available again", rl.cfg.Upstream)
+		}
+		down = err != nil
+	}
+}
+
+// flush forwards the sealed segments in order and stops at the first
+// segment which could not be forwarded. Without segments an empty batch
+// reports the relay to the upstream.
+func (rl *Relay) flush() error {
+	complete := rl.spool.Covered()
+	segments, err := rl.spool.Segments()
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		return rl.send(routes.Batch{Complete: complete})
+	}
+
+	for i, path := range segments {
+		batch := routes.Batch{Backlog: len(segments) - i - 1}
+		if batch.Backlog == 0 {
+			batch.Complete = complete
+		}
+		err := rl.forward(path, batch)
+		if errors.Is(err, errRejected) {
+			rl.log.Errorf("Dropped %s: %v", path, err)
+		} else if err != nil {
+			return err
+		}
+		err = os.Remove(path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forward posts the samples of a segment as batch to the upstream.
+func (rl *Relay) forward(path string, batch routes.Batch) error {
+	samples, skipped, err := ReadSegment(path)
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		rl.log.Warnf("Skipped %d broken samples of %s", skipped, path)
+	}
+	batch.Samples = samples
+	return rl.send(batch)
+}
+
+// send posts batch to the upstream and keeps the sessions of the response
+// to tag measurements with.
+func (rl *Relay) send(batch routes.Batch) error {
+	batch.Relay, batch.Sent = rl.name, time.Now()
+	var out routes.BatchResponse
+	err := rl.post("/ingest-batch", batch, &out)
+	var status *statusError
+	if errors.As(err, &status) && (status.code == http.StatusBadRequest || status.code == http.StatusRequestEntityTooLarge) {
+		return fmt.Errorf("%w: %v", errRejected, err)
+	} else if err != nil {
+		return err
+	}
+
+	if out.Dropped > 0 {
+		rl.log.Warnf("Upstream dropped %d of %d samples", out.Dropped, len(batch.Samples))
+	}
+	rl.mu.Lock()
+	rl.latest, rl.runs = out.Latest, out.Runs
+	rl.mu.Unlock()
+	return nil
+}
+
+// statusError is an unexpected response of the upstream.
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return "upstream responded " + e.status
+}
+
+// post posts body as JSON to the route of the upstream and decodes the
+// response into out.
+func (rl *Relay) post(route string, body interface{}, out interface{}) error {
+	tmp, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, rl.cfg.Upstream+route, bytes.NewReader(tmp))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if rl.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+rl.cfg.Token)
+	}
+
+	resp, err := rl.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,332 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sbaeurle/comb/metrics/clock"
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/results"
+	"github.com/sbaeurle/comb/metrics/routes"
+	"go.uber.org/zap"
+)
+
+func TestSpool(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenSpool(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"1", "2", "3", "4", "5"} {
+		err := s.Append(routes.BatchSample{Url: "/test", Body: json.RawMessage(v)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	segments, err := s.Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, len(segments))
+	}
+
+	// A relay restarted after a crash seals the open segment, a partially
+	// written sample is skipped.
+	f, err := os.OpenFile(s.path(s.seq, openExt), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"url": "/te`)
+	f.Close()
+
+	s, err = OpenSpool(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments, err = s.Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bodies []string
+	for _, path := range segments {
+		samples, _, err := ReadSegment(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sample := range samples {
+			bodies = append(bodies, string(sample.Body))
+		}
+	}
+	expected := []string{"1", "2", "3", "4", "5"}
+	if !reflect.DeepEqual(expected, bodies) {
+		t.Fatalf("expected: %v, got: %v", expected, bodies)
+	}
+	_, skipped, err := ReadSegment(segments[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, skipped)
+	}
+
+	// New samples continue after the recovered segments.
+	err = s.Append(routes.BatchSample{Url: "/test", Body: json.RawMessage("6")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Seal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	segments, err = s.Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 4 {
+		t.Fatalf("expected: %v, got: %v", 4, len(segments))
+	}
+}
+
+func TestRelay(t *testing.T) {
+	// The upstream is unavailable for the first batches.
+	var mu sync.Mutex
+	var received []routes.BatchSample
+	failures := 2
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path != "/ingest-batch" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch routes.Batch
+		err := json.NewDecoder(r.Body).Decode(&batch)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = append(received, batch.Samples...)
+		json.NewEncoder(w).Encode(routes.BatchResponse{Accepted: len(batch.Samples)})
+	}))
+	defer upstream.Close()
+
+	cfg := config.RelayConfig{Upstream: upstream.URL + "/", Token: "secret", SpoolFolder: t.TempDir(), BatchSize: 2, Interval: 10 * time.Millisecond}
+	rl, err := New(zap.NewNop().Sugar(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := mux.NewRouter()
+	rl.RegisterRoutes(r, []config.EndpointConfig{{Name: "test", Url: "/test"}})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	type request struct {
+		url    string
+		header map[string]string
+		body   string
+		status int
+	}
+	requests := []request{
+		{url: "/test", header: map[string]string{routes.HeaderNode: "jetson", routes.HeaderSession: "a"}, body: `{"value": 1}`, status: http.StatusCreated},
+		{url: "/sessions/b/test", header: map[string]string{routes.HeaderSourceToken: "token"}, body: `{"value": 2}`, status: http.StatusCreated},
+		{url: "/test", body: `{"value": 3}`, status: http.StatusCreated},
+		{url: "/test", body: `{"value": `, status: http.StatusBadRequest},
+		{url: "/unknown", body: `{"value": 4}`, status: http.StatusNotFound},
+	}
+	var sent []time.Time
+	for _, req := range requests {
+		r, err := http.NewRequest(http.MethodPost, srv.URL+req.url, bytes.NewBufferString(req.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range req.header {
+			r.Header.Set(k, v)
+		}
+		sent = append(sent, time.Now())
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != req.status {
+			t.Fatalf("expected: %v, got: %v", req.status, resp.StatusCode)
+		}
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go rl.Run(stop)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected: %v, got: %v", 3, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	type forwarded struct {
+		session string
+		url     string
+		header  map[string]string
+		body    string
+	}
+	expected := []forwarded{
+		{session: "a", url: "/test", header: map[string]string{routes.HeaderNode: "jetson"}, body: `{"value":1}`},
+		{session: "b", url: "/test", header: map[string]string{routes.HeaderSourceToken: "token"}, body: `{"value":2}`},
+		{url: "/test", header: map[string]string{}, body: `{"value":3}`},
+	}
+	for i, sample := range received {
+		got := forwarded{session: sample.Session, url: sample.Url, header: sample.Header, body: string(sample.Body)}
+		if got.header == nil {
+			got.header = map[string]string{}
+		}
+		if !reflect.DeepEqual(expected[i], got) {
+			t.Fatalf("expected: %v, got: %v", expected[i], got)
+		}
+		// Samples keep the time the relay received them.
+		if sample.Received.Before(sent[i]) || sample.Received.After(sent[i+1]) {
+			t.Fatalf("expected: %v, got: %v", sent[i], sample.Received)
+		}
+	}
+
+	segments, err := rl.spool.Segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 0 {
+		t.Fatalf("expected: %v, got: %v", 0, len(segments))
+	}
+}
+
+func postJSON(t *testing.T, url string, header map[string]string, body interface{}) *http.Response {
+	tmp, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(tmp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestRelayUpstream(t *testing.T) {
+	log := zap.NewNop().Sugar()
+	cfg := config.Config{
+		BufferSize: 10,
+		RootFolder: t.TempDir(),
+		Endpoints: []config.EndpointConfig{
+			{Name: "test", Url: "/test", Module: "GENERIC", Fields: []string{"value"}, Metrics: map[string][]string{"value": {"AVG"}}},
+		},
+		Relay: config.RelayConfig{DrainTimeout: 5 * time.Second},
+	}
+	r := mux.NewRouter()
+	cs, err := routes.NewControlService(log, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.RegisterControlRoutes(r)
+	err = routes.RegisterRoutes(r, log, cfg, cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes.NewClockService(log, cs).RegisterClockRoutes(r)
+	upstream := httptest.NewServer(r)
+	defer upstream.Close()
+
+	rl, err := New(log, config.RelayConfig{Upstream: upstream.URL, SpoolFolder: t.TempDir(), Interval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := mux.NewRouter()
+	rl.RegisterRoutes(rr, cfg.Endpoints)
+	srv := httptest.NewServer(rr)
+	defer srv.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go rl.Run(stop)
+
+	resp := postJSON(t, upstream.URL+"/start-benchmark", nil, nil)
+	var info routes.SessionInfo
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := postJSON(t, upstream.URL+"/start-run", nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+
+	// The relay answers time requests once it synchronized with the upstream.
+	var ex clock.Exchange
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp := postJSON(t, srv.URL+"/clock/sync", nil, clock.Exchange{Origin: time.Now().UnixNano()})
+		if resp.StatusCode == http.StatusOK {
+			err := json.NewDecoder(resp.Body).Decode(&ex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ex.Destination = time.Now().UnixNano()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	header := map[string]string{routes.HeaderNode: "jetson"}
+	if resp := postJSON(t, srv.URL+routes.ClockReportURL, header, ex); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected: %v, got: %v", http.StatusCreated, resp.StatusCode)
+	}
+
+	// Measurements spooled right before the run ends are part of it.
+	for _, v := range []float64{1, 3} {
+		if resp := postJSON(t, srv.URL+"/test", header, map[string]float64{"value": v}); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected: %v, got: %v", http.StatusCreated, resp.StatusCode)
+		}
+	}
+	if resp := postJSON(t, upstream.URL+"/end-run", nil, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+
+	res, err := results.Read(filepath.Join(info.Root, "run001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Endpoints["test"].Samples != 2 {
+		t.Fatalf("expected: %v, got: %v", 2, res.Endpoints["test"].Samples)
+	}
+	if _, ok := res.Clocks["jetson"]; !ok {
+		t.Fatalf("expected: %v, got: %v", "jetson", res.Clocks)
+	}
+}
@@ -0,0 +1,202 @@
+// Package relay stores measurements near the workloads and forwards them in
+// batches to an upstream metric service, which ingests them with their
+// original receive time.
+package relay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sbaeurle/comb/metrics/routes"
+)
+
+// Extensions of the segment files of a spool. Samples are appended to the
+// open segment, sealed segments are complete batches waiting to be forwarded.
+const (
+	openExt   = ".open"
+	sealedExt = ".jsonl"
+)
+
+// Spool persists samples in segment files of at most batchSize samples,
+// one JSON sample per line. Segments are sealed when full or on Seal and
+// removed once they were forwarded, so samples survive disconnects and
+// restarts of the relay.
+type Spool struct {
+	mu        sync.Mutex
+	dir       string
+	batchSize int
+	seq       uint64
+	file      *os.File
+	count     int
+	// sealedAt is the time the last segment was sealed, sealed is
+	// signalled when a segment was sealed.
+	sealedAt time.Time
+	sealed   chan struct{}
+}
+
+// OpenSpool opens the spool in dir. Segments left open by a previous relay
+// are sealed to forward them first.
+func OpenSpool(dir string, batchSize int) (*Spool, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Spool{dir: dir, batchSize: batchSize, sealed: make(chan struct{}, 1)}
+	for _, e := range entries {
+		seq, ext, ok := parseSegment(e.Name())
+		if !ok {
+			continue
+		}
+		if seq > s.seq {
+			s.seq = seq
+		}
+		if ext == openExt {
+			err := os.Rename(filepath.Join(dir, e.Name()), s.path(seq, sealedExt))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+func parseSegment(name string) (uint64, string, bool) {
+	ext := filepath.Ext(name)
+	if ext != openExt && ext != sealedExt {
+		return 0, "", false
+	}
+	seq, err := strconv.ParseUint(strings.TrimSuffix(name, ext), 10, 64)
+	return seq, ext, err == nil
+}
+
+func (s *Spool) path(seq uint64, ext string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%016d%s", seq, ext))
+}
+
+// Append stores sample in the open segment.
+func (s *Spool) Append(sample routes.BatchSample) error {
+	line, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		s.seq++
+		s.file, err = os.OpenFile(s.path(s.seq, openExt), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = s.file.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+	s.count++
+	if s.count >= s.batchSize {
+		return s.seal()
+	}
+	return nil
+}
+
+// Seal seals the open segment, if any.
+func (s *Spool) Seal() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seal()
+}
+
+func (s *Spool) seal() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Sync()
+	if err == nil {
+		err = s.file.Close()
+	}
+	s.file, s.count = nil, 0
+	if err != nil {
+		return err
+	}
+	err = os.Rename(s.path(s.seq, openExt), s.path(s.seq, sealedExt))
+	if err != nil {
+		return err
+	}
+	s.sealedAt = time.Now()
+
+	select {
+	case s.sealed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Covered returns the time before which all samples were stored in sealed
+// segments.
+func (s *Spool) Covered() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return time.Now()
+	}
+	return s.sealedAt
+}
+
+// Sealed notifies about sealed segments.
+func (s *Spool) Sealed() <-chan struct{} {
+	return s.sealed
+}
+
+// Segments lists the sealed segments, oldest first.
+func (s *Spool) Segments() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		if _, ext, ok := parseSegment(e.Name()); ok && ext == sealedExt {
+			out = append(out, filepath.Join(s.dir, e.Name()))
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// ReadSegment reads the samples of a segment. Lines which are no sample,
+// e.g. written partially before the relay stopped, are skipped and counted.
+func ReadSegment(path string) ([]routes.BatchSample, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var samples []routes.BatchSample
+	skipped := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var sample routes.BatchSample
+		if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+			skipped++
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	return samples, skipped, scanner.Err()
+}
@@ -0,0 +1,215 @@
+// Package report renders a self-contained HTML report of a benchmark folder
+// from the results and raw outputs of its runs.
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/results"
+)
+
+// ReportFile is written to the benchmark folder.
+const ReportFile = "report.html"
+
+//go:embed report.html.tmpl
+var page string
+
+var tmpl = template.Must(template.New("report").Funcs(template.FuncMap{"format": Format}).Parse(page))
+
+// Report is the content of the report of a benchmark.
+type Report struct {
+	Name        string
+	Generated   time.Time
+	Runs        []Run
+	Comparisons []Comparison
+}
+
+// Run holds the aggregated metrics and charts of a single run.
+type Run struct {
+	Name      string
+	Matching  string
+	Status    string
+	Metrics   []Metric
+	Endpoints []Endpoint
+}
+
+// Metric is a single aggregated value of results.json.
+type Metric struct {
+	Endpoint string
+	Name     string
+	Value    float64
+	Unit     string
+}
+
+// Endpoint holds the charts of the fields collected by an endpoint.
+type Endpoint struct {
+	Name   string
+	Fields []Field
+}
+
+// Field is a time series of raw values and their distribution.
+type Field struct {
+	Name         string
+	Samples      int
+	Series       template.HTML
+	Distribution template.HTML
+}
+
+// Comparison shows a metric across all complete runs.
+type Comparison struct {
+	Endpoint string
+	Name     string
+	Chart    template.HTML
+}
+
+// Generate writes the report of the benchmark folder path and returns the
+// path of the report. The configuration recorded in the manifest of a run
+// takes precedence over cfg.
+func Generate(path string, cfg config.Config) (string, error) {
+	r, err := Load(path, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	out := filepath.Join(path, ReportFile)
+	f, err := os.Create(out)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	err = tmpl.Execute(f, r)
+	if err != nil {
+		return "", err
+	}
+	return out, f.Close()
+}
+
+// Load collects the report of the benchmark folder path.
+func Load(path string, cfg config.Config) (Report, error) {
+	r := Report{Name: filepath.Base(path), Generated: time.Now()}
+	if idx, err := results.ReadIndex(path); err == nil && idx.Name != "" {
+		r.Name = idx.Name
+	}
+
+	runs, err := results.ListRuns(path)
+	if err != nil {
+		return r, err
+	}
+
+	var labels []string
+	values := make(map[Metric]map[string]float64)
+	for _, run := range runs {
+		// Aborted runs hold partial data only.
+		if run.Aborted {
+			continue
+		}
+		out := Run{Name: run.Name, Matching: matching(run.Matching), Status: status(run)}
+
+		for _, endpoint := range results.RunConfig(run.Path, cfg).Endpoints {
+			e, err := loadEndpoint(run.Path, endpoint)
+			if err != nil {
+				return r, fmt.Errorf("%s: %w", run.Path, err)
+			}
+			if len(e.Fields) > 0 {
+				out.Endpoints = append(out.Endpoints, e)
+			}
+		}
+
+		if run.Valid() {
+			res, err := results.Read(run.Path)
+			if err != nil {
+				return r, err
+			}
+			label := run.Name
+			if out.Matching != "" {
+				label = fmt.Sprintf("%s (%s)", run.Name, out.Matching)
+			}
+			labels = append(labels, label)
+
+			for endpoint, metrics := range res.Results {
+				for name, v := range metrics {
+					out.Metrics = append(out.Metrics, Metric{Endpoint: endpoint, Name: name, Value: v, Unit: res.Units[endpoint][name]})
+
+					key := Metric{Endpoint: endpoint, Name: name}
+					if values[key] == nil {
+						values[key] = make(map[string]float64)
+					}
+					values[key][label] = v
+				}
+			}
+			sort.Slice(out.Metrics, func(i, j int) bool { return less(out.Metrics[i], out.Metrics[j]) })
+		}
+
+		r.Runs = append(r.Runs, out)
+	}
+
+	keys := make([]Metric, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
+	for _, k := range keys {
+		var l []string
+		var v []float64
+		for _, label := range labels {
+			if tmp, ok := values[k][label]; ok {
+				l = append(l, label)
+				v = append(v, tmp)
+			}
+		}
+		r.Comparisons = append(r.Comparisons, Comparison{Endpoint: k.Endpoint, Name: k.Name, Chart: BarChart(l, v)})
+	}
+	return r, nil
+}
+
+// loadEndpoint charts every field of the endpoint which is aggregated as metric.
+func loadEndpoint(path string, endpoint config.EndpointConfig) (Endpoint, error) {
+	e := Endpoint{Name: endpoint.Name}
+	fields, samples, err := results.ReadSamples(path, endpoint)
+	if err != nil {
+		return e, err
+	}
+
+	for _, name := range fields {
+		values := samples[name]
+		e.Fields = append(e.Fields, Field{Name: name, Samples: len(values), Series: LineChart(values), Distribution: Histogram(values)})
+	}
+	return e, nil
+}
+
+// less orders metrics by endpoint and name, ignoring their value.
+func less(a, b Metric) bool {
+	if a.Endpoint != b.Endpoint {
+		return a.Endpoint < b.Endpoint
+	}
+	return a.Name < b.Name
+}
+
+func matching(m map[string]string) string {
+	parts := make([]string, 0, len(m))
+	for workload, assignment := range m {
+		parts = append(parts, fmt.Sprintf("%s=%s", workload, assignment))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+func status(run results.Run) string {
+	switch {
+	case run.Incomplete:
+		return "incomplete"
+	case run.Complete:
+		return "complete"
+	default:
+		return "running"
+	}
+}
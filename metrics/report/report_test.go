@@ -0,0 +1,84 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/results"
+)
+
+func TestGenerate(t *testing.T) {
+	root := t.TempDir()
+	cfg := config.Config{Endpoints: []config.EndpointConfig{{
+		Name:    "tracking",
+		Header:  true,
+		Fields:  []string{"frame-number", "processing-time"},
+		Outputs: []string{"tracking.csv"},
+		Metrics: map[string][]string{"processing-time": {"AVG"}},
+	}}}
+
+	for i, run := range []string{"run001", "run002"} {
+		path := filepath.Join(root, run)
+		err := os.Mkdir(path, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(path, "tracking.csv"), []byte("frame-number,processing-time\n1,10\n2,12\n3,11\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = results.Write(path, results.Results{
+			Matching: map[string]string{"Tracking": []string{"Jetson", "APU"}[i]},
+			Results:  map[string]map[string]float64{"tracking": {"processing-time-AVG": 11 + float64(i)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := Generate(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html := string(tmp)
+	for _, expected := range []string{"run002 (Tracking=APU)", "tracking: processing-time (3 samples)", "processing-time-AVG", "<polyline", "<rect"} {
+		if !strings.Contains(html, expected) {
+			t.Fatalf("expected: %v, got: %v", expected, html)
+		}
+	}
+}
+
+func TestDownsample(t *testing.T) {
+	type testCase struct {
+		values []float64
+		n      int
+		out    []float64
+	}
+	tests := map[string]testCase{
+		"short":  {values: []float64{1, 2}, n: 4, out: []float64{1, 2}},
+		"halved": {values: []float64{1, 3, 5, 7}, n: 2, out: []float64{2, 6}},
+		"uneven": {values: []float64{1, 2, 3, 4, 5}, n: 2, out: []float64{1.5, 4}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			out := downsample(tc.values, tc.n)
+			if len(out) != len(tc.out) {
+				t.Fatalf("expected: %v, got: %v", tc.out, out)
+			}
+			for i := range out {
+				if out[i] != tc.out[i] {
+					t.Fatalf("expected: %v, got: %v", tc.out, out)
+				}
+			}
+		})
+	}
+}
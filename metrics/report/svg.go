@@ -0,0 +1,201 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Dimensions of the charts in pixels.
+const (
+	chartWidth   = 720
+	chartHeight  = 240
+	marginLeft   = 64
+	marginRight  = 16
+	marginTop    = 16
+	marginBottom = 28
+	barHeight    = 22
+	labelWidth   = 260
+	maxPoints    = 800
+	bins         = 30
+)
+
+// scale maps values of [min, max] to [from, to].
+type scale struct {
+	min, max float64
+	from, to float64
+}
+
+func (s scale) at(v float64) float64 {
+	if s.max == s.min {
+		return (s.from + s.to) / 2
+	}
+	return s.from + (v-s.min)/(s.max-s.min)*(s.to-s.from)
+}
+
+// LineChart plots the values over their sample index with a dashed line at their mean.
+func LineChart(values []float64) template.HTML {
+	values = finite(values)
+	if len(values) == 0 {
+		return ""
+	}
+	points := downsample(values, maxPoints)
+	min, max := bounds(values)
+	x := scale{min: 0, max: float64(len(points) - 1), from: marginLeft, to: chartWidth - marginRight}
+	y := scale{min: min, max: max, from: chartHeight - marginBottom, to: marginTop}
+
+	var b strings.Builder
+	open(&b, chartWidth, chartHeight)
+	axes(&b, y, chartWidth)
+	fmt.Fprintf(&b, `<text x="%d" y="%d">0</text>`, marginLeft, chartHeight-8)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%d</text>`, chartWidth-marginRight, chartHeight-8, len(values)-1)
+
+	b.WriteString(`<polyline class="series" points="`)
+	for i, v := range points {
+		fmt.Fprintf(&b, "%.1f,%.1f ", x.at(float64(i)), y.at(v))
+	}
+	b.WriteString(`"/>`)
+
+	m := y.at(mean(values))
+	fmt.Fprintf(&b, `<line class="mean" x1="%d" y1="%.1f" x2="%d" y2="%.1f"><title>mean %s</title></line>`, marginLeft, m, chartWidth-marginRight, m, Format(mean(values)))
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// Histogram plots the distribution of the values.
+func Histogram(values []float64) template.HTML {
+	values = finite(values)
+	if len(values) == 0 {
+		return ""
+	}
+	min, max := bounds(values)
+	counts := make([]float64, bins)
+	for _, v := range values {
+		i := 0
+		if max > min {
+			i = int((v - min) / (max - min) * bins)
+		}
+		if i >= bins {
+			i = bins - 1
+		}
+		counts[i]++
+	}
+	_, top := bounds(counts)
+
+	x := scale{min: 0, max: bins, from: marginLeft, to: chartWidth - marginRight}
+	y := scale{min: 0, max: top, from: chartHeight - marginBottom, to: marginTop}
+
+	var b strings.Builder
+	open(&b, chartWidth, chartHeight)
+	axes(&b, y, chartWidth)
+	fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`, marginLeft, chartHeight-8, Format(min))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, chartWidth-marginRight, chartHeight-8, Format(max))
+
+	width := (x.to - x.from) / bins
+	for i, c := range counts {
+		if c == 0 {
+			continue
+		}
+		lower := min + (max-min)*float64(i)/bins
+		upper := min + (max-min)*float64(i+1)/bins
+		fmt.Fprintf(&b, `<rect class="bar" x="%.1f" y="%.1f" width="%.1f" height="%.1f"><title>%s to %s: %d</title></rect>`,
+			x.at(float64(i))+1, y.at(c), width-2, y.at(0)-y.at(c), Format(lower), Format(upper), int(c))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// BarChart compares one value per label with horizontal bars.
+func BarChart(labels []string, values []float64) template.HTML {
+	if len(values) == 0 {
+		return ""
+	}
+	min, max := bounds(append(finite(values), 0))
+	height := marginTop + marginBottom + barHeight*len(values)
+	x := scale{min: min, max: max, from: labelWidth, to: chartWidth - marginRight}
+
+	var b strings.Builder
+	open(&b, chartWidth, height)
+	fmt.Fprintf(&b, `<line class="axis" x1="%.1f" y1="%d" x2="%.1f" y2="%d"/>`, x.at(0), marginTop, x.at(0), height-marginBottom)
+	fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`, labelWidth, height-8, Format(min))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, chartWidth-marginRight, height-8, Format(max))
+
+	for i, v := range values {
+		top := marginTop + i*barHeight
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, labelWidth-6, top+barHeight-7, template.HTMLEscapeString(labels[i]))
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		from, to := x.at(0), x.at(v)
+		if to < from {
+			from, to = to, from
+		}
+		fmt.Fprintf(&b, `<rect class="bar" x="%.1f" y="%d" width="%.1f" height="%d"><title>%s: %s</title></rect>`,
+			from, top+3, math.Max(to-from, 1), barHeight-6, template.HTMLEscapeString(labels[i]), Format(v))
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+func open(b *strings.Builder, width, height int) {
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d">`, width, height, width, height)
+}
+
+// axes draws both axes with labels of the y axis.
+func axes(b *strings.Builder, y scale, width int) {
+	fmt.Fprintf(b, `<line class="axis" x1="%d" y1="%d" x2="%d" y2="%d"/>`, marginLeft, marginTop, marginLeft, chartHeight-marginBottom)
+	fmt.Fprintf(b, `<line class="axis" x1="%d" y1="%d" x2="%d" y2="%d"/>`, marginLeft, chartHeight-marginBottom, width-marginRight, chartHeight-marginBottom)
+	for i := 0; i <= 4; i++ {
+		v := y.min + (y.max-y.min)*float64(i)/4
+		fmt.Fprintf(b, `<line class="grid" x1="%d" y1="%.1f" x2="%d" y2="%.1f"/>`, marginLeft, y.at(v), width-marginRight, y.at(v))
+		fmt.Fprintf(b, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`, marginLeft-6, y.at(v)+4, Format(v))
+	}
+}
+
+// downsample reduces the values to at most n points by averaging consecutive values.
+func downsample(values []float64, n int) []float64 {
+	if len(values) <= n {
+		return values
+	}
+	out := make([]float64, n)
+	for i := range out {
+		from := i * len(values) / n
+		to := (i + 1) * len(values) / n
+		out[i] = mean(values[from:to])
+	}
+	return out
+}
+
+func finite(values []float64) []float64 {
+	out := make([]float64, 0, len(values))
+	for _, v := range values {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func bounds(values []float64) (float64, float64) {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	return min, max
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// Format prints v with four significant digits.
+func Format(v float64) string {
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
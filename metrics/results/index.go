@@ -0,0 +1,52 @@
+package results
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// IndexFile lists all runs of a benchmark with their folder and matching.
+const IndexFile = "index.json"
+
+// Index is the machine-readable table of contents of a benchmark folder.
+type Index struct {
+	Name    string       `json:"name,omitempty"`
+	Session string       `json:"session"`
+	Runs    []IndexEntry `json:"runs"`
+}
+
+// IndexEntry locates a single run relative to the benchmark folder.
+type IndexEntry struct {
+	Run      int               `json:"run"`
+	Path     string            `json:"path"`
+	Started  time.Time         `json:"started"`
+	Matching map[string]string `json:"matching"`
+}
+
+// ReadIndex loads the index of the benchmark folder dir.
+func ReadIndex(dir string) (Index, error) {
+	var idx Index
+	tmp, err := os.ReadFile(filepath.Join(dir, IndexFile))
+	if err != nil {
+		return idx, err
+	}
+	err = json.Unmarshal(tmp, &idx)
+	return idx, err
+}
+
+// WriteIndex stores the index in the benchmark folder dir.
+func WriteIndex(dir string, idx Index) error {
+	tmp, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, IndexFile)
+	err = os.WriteFile(path+".tmp", tmp, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
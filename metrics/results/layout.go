@@ -0,0 +1,170 @@
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sbaeurle/comb/metrics/config"
+)
+
+// Defaults of the result folder layout.
+const (
+	DefaultDateFormat = "20060102_150405"
+	DefaultBenchmark  = "{{.Date}}"
+	DefaultRun        = `run{{printf "%03d" .Run}}`
+)
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._=+-]+`)
+
+// BenchmarkVars are available in the benchmark folder template.
+type BenchmarkVars struct {
+	Name string
+	Date string
+}
+
+// RunVars are available in the run folder template.
+type RunVars struct {
+	Name      string
+	Date      string
+	Run       int
+	Matching  map[string]string
+	Workloads map[string]Deployment
+}
+
+// Deployment describes on which node and with which tag a workload ran.
+type Deployment struct {
+	Node string `json:"node"`
+	Tag  string `json:"tag"`
+}
+
+// Layout builds the folder names of benchmarks and runs from Go templates.
+type Layout struct {
+	dateFormat string
+	benchmark  *template.Template
+	run        *template.Template
+}
+
+func NewLayout(cfg config.Config) (*Layout, error) {
+	l := &Layout{dateFormat: cfg.DateFormat}
+	if l.dateFormat == "" {
+		l.dateFormat = DefaultDateFormat
+	}
+
+	benchmark := cfg.Layout.Benchmark
+	if benchmark == "" {
+		benchmark = DefaultBenchmark
+	}
+	run := cfg.Layout.Run
+	if run == "" {
+		run = DefaultRun
+	}
+
+	var err error
+	l.benchmark, err = template.New("benchmark").Option("missingkey=zero").Parse(benchmark)
+	if err != nil {
+		return nil, fmt.Errorf("Layout.Benchmark: %w", err)
+	}
+	l.run, err = template.New("run").Option("missingkey=zero").Parse(run)
+	if err != nil {
+		return nil, fmt.Errorf("Layout.Run: %w", err)
+	}
+	return l, nil
+}
+
+// Date formats t with the configured date format.
+func (l *Layout) Date(t time.Time) string {
+	return t.Format(l.dateFormat)
+}
+
+// CreateBenchmark creates the folder of a new benchmark below root.
+func (l *Layout) CreateBenchmark(root string, vars BenchmarkVars) (string, error) {
+	name, err := execute(l.benchmark, vars, false)
+	if err != nil {
+		return "", err
+	}
+	return UniqueDir(filepath.Join(root, name))
+}
+
+// CreateRun creates the folder of a new run below the benchmark folder and
+// returns its path relative to the benchmark folder.
+func (l *Layout) CreateRun(benchmark string, vars RunVars) (string, error) {
+	name, err := execute(l.run, vars, true)
+	if err != nil {
+		return "", err
+	}
+	path, err := UniqueDir(filepath.Join(benchmark, name))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(benchmark, path)
+}
+
+// execute renders the template to a relative path. Every path element is
+// restricted to safe characters, so templates cannot escape the result root.
+// Unless nested is set, the result is a single path element.
+func execute(tmpl *template.Template, vars interface{}, nested bool) (string, error) {
+	var out strings.Builder
+	err := tmpl.Execute(&out, vars)
+	if err != nil {
+		return "", err
+	}
+
+	parts := []string{out.String()}
+	if nested {
+		parts = strings.Split(out.String(), "/")
+	}
+
+	var elems []string
+	for _, e := range parts {
+		e = unsafeChars.ReplaceAllString(strings.TrimSpace(e), "_")
+		if e == "" || e == "." || e == ".." {
+			continue
+		}
+		elems = append(elems, e)
+	}
+	if len(elems) == 0 {
+		return "", fmt.Errorf("template %s resulted in an empty path", tmpl.Name())
+	}
+	return filepath.Join(elems...), nil
+}
+
+// UniqueDir creates the directory path. If it already exists, a numbered
+// suffix is appended until an unused name is found.
+func UniqueDir(path string) (string, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return "", err
+	}
+
+	candidate := path
+	for i := 2; ; i++ {
+		err = os.Mkdir(candidate, 0755)
+		if err == nil {
+			return candidate, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s-%d", path, i)
+	}
+}
+
+// Deployments extracts where each workload ran from the manifest sent by the orchestrator.
+func Deployments(orchestration json.RawMessage) map[string]Deployment {
+	var tmp struct {
+		Workloads map[string]Deployment `json:"workloads"`
+	}
+	if len(orchestration) > 0 {
+		json.Unmarshal(orchestration, &tmp)
+	}
+	if tmp.Workloads == nil {
+		tmp.Workloads = make(map[string]Deployment)
+	}
+	return tmp.Workloads
+}
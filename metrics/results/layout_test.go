@@ -0,0 +1,89 @@
+package results
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sbaeurle/comb/metrics/config"
+)
+
+func TestLayoutCreateRun(t *testing.T) {
+	type testCase struct {
+		template string
+		out      string
+	}
+	vars := RunVars{
+		Name:      "nightly",
+		Date:      "2021-06-01",
+		Run:       7,
+		Matching:  map[string]string{"Detection": "Jetson-10.0.0.2-l4t"},
+		Workloads: Deployments(json.RawMessage(`{"workloads": {"Detection": {"node": "10.0.0.2", "tag": "l4t"}}}`)),
+	}
+	tests := map[string]testCase{
+		"default":   {template: "", out: "run007"},
+		"matching":  {template: "{{.Date}}/{{.Matching.Detection}}-{{.Run}}", out: "2021-06-01/Jetson-10.0.0.2-l4t-7"},
+		"workloads": {template: "{{.Name}}/{{(index .Workloads \"Detection\").Tag}}", out: "nightly/l4t"},
+		"sanitized": {template: "../{{.Name}} #{{.Run}}", out: "nightly_7"},
+		"missing":   {template: "{{.Matching.Tracking}}x", out: "x"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			l, err := NewLayout(config.Config{Layout: config.LayoutConfig{Run: tc.template}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := l.CreateRun(t.TempDir(), vars)
+			if err != nil {
+				t.Fatalf("expected: %v, got: %v", nil, err)
+			}
+			if out != filepath.FromSlash(tc.out) {
+				t.Fatalf("expected: %v, got: %v", tc.out, out)
+			}
+		})
+	}
+}
+
+func TestLayoutCreateBenchmark(t *testing.T) {
+	root := t.TempDir()
+	l, err := NewLayout(config.Config{DateFormat: "2006-01", Layout: config.LayoutConfig{Benchmark: "{{.Name}}/{{.Date}}"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"nightly_2021-06", "nightly_2021-06-2"}
+	for _, name := range expected {
+		out, err := l.CreateBenchmark(root, BenchmarkVars{Name: "nightly", Date: "2021-06"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != filepath.Join(root, name) {
+			t.Fatalf("expected: %v, got: %v", filepath.Join(root, name), out)
+		}
+	}
+}
+
+func TestListRunsIndex(t *testing.T) {
+	root := t.TempDir()
+	err := os.MkdirAll(filepath.Join(root, "2021-06-01", "Jetson-1"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WriteIndex(root, Index{Session: "abc", Runs: []IndexEntry{
+		{Run: 1, Path: "2021-06-01/Jetson-1", Matching: map[string]string{"Detection": "Jetson"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := FindRun(root, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Name != "2021-06-01/Jetson-1" || run.Path != filepath.Join(root, "2021-06-01", "Jetson-1") || run.Matching["Detection"] != "Jetson" {
+		t.Fatalf("expected: %v, got: %v", "2021-06-01/Jetson-1", run)
+	}
+}
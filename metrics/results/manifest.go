@@ -0,0 +1,48 @@
+package results
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sbaeurle/comb/metrics/config"
+)
+
+// ManifestFile describes how a run was produced.
+const ManifestFile = "manifest.json"
+
+// Manifest records everything needed to reproduce a run. Orchestration holds
+// the information sent by the orchestrator, e.g. its configuration, the
+// resolved commands, image digests and node facts.
+type Manifest struct {
+	Version       string            `json:"version"`
+	Session       string            `json:"session"`
+	Run           int               `json:"run"`
+	Start         time.Time         `json:"start"`
+	End           *time.Time        `json:"end,omitempty"`
+	Matching      map[string]string `json:"matching"`
+	Config        config.Config     `json:"config"`
+	ConfigVersion string            `json:"configVersion,omitempty"`
+	Orchestration json.RawMessage   `json:"orchestration,omitempty"`
+}
+
+// WriteManifest stores m in the run folder dir.
+func WriteManifest(dir string, m Manifest) error {
+	tmp, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestFile), tmp, 0600)
+}
+
+// ReadManifest loads the manifest of the run folder dir.
+func ReadManifest(dir string) (Manifest, error) {
+	var m Manifest
+	tmp, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(tmp, &m)
+	return m, err
+}
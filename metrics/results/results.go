@@ -0,0 +1,235 @@
+// Package results describes the layout of the result folder written by the
+// metric service and reads benchmarks and runs back from it.
+package results
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sbaeurle/comb/metrics/clock"
+	"github.com/sbaeurle/comb/metrics/measurement"
+)
+
+// ResultsFile holds the collected metrics of a run.
+const ResultsFile = "results.json"
+
+var runPattern = regexp.MustCompile(`^run(\d+)$`)
+
+// Results is the content of the results file of a run.
+type Results struct {
+	Matching  map[string]string             `json:"matching"`
+	Sources   []measurement.Source          `json:"sources,omitempty"`
+	Clocks    map[string]clock.Report       `json:"clocks,omitempty"`
+	Status    string                        `json:"status,omitempty"`
+	Endpoints map[string]EndpointStatus     `json:"endpoints,omitempty"`
+	Results   map[string]map[string]float64 `json:"results"`
+	// Units of the metrics per endpoint, metrics without unit are left out.
+	Units map[string]map[string]string `json:"units,omitempty"`
+}
+
+// Status of a run and its endpoints after checking their completeness.
+const (
+	StatusOK     = "OK"
+	StatusWarn   = "WARN"
+	StatusFailed = "FAILED"
+)
+
+// EndpointStatus describes the completeness of the data an endpoint received during a run.
+type EndpointStatus struct {
+	Status   string   `json:"status"`
+	Samples  int      `json:"samples"`
+	MaxGapMs float64  `json:"maxGapMs"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// Benchmark is a single benchmark folder below the result root.
+type Benchmark struct {
+	Name string `json:"name"`
+	Path string `json:"-"`
+	Runs int    `json:"runs"`
+}
+
+// Run is a single run folder of a benchmark. Complete runs have a results
+// file, incomplete runs were interrupted before they ended and aborted runs
+// were stopped by the orchestrator.
+type Run struct {
+	Name       string            `json:"name"`
+	Index      int               `json:"index"`
+	Path       string            `json:"-"`
+	Matching   map[string]string `json:"matching,omitempty"`
+	Complete   bool              `json:"complete"`
+	Incomplete bool              `json:"incomplete,omitempty"`
+	Aborted    bool              `json:"aborted,omitempty"`
+	Status     string            `json:"status,omitempty"`
+}
+
+// Valid reports whether the run ended regularly with complete data, so its
+// results can be evaluated.
+func (r Run) Valid() bool {
+	return r.Complete && !r.Incomplete && !r.Aborted && r.Status != StatusFailed
+}
+
+// Write stores r in the results file of the run folder dir.
+func Write(dir string, r Results) ([]byte, error) {
+	tmp, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return tmp, os.WriteFile(filepath.Join(dir, ResultsFile), tmp, 0600)
+}
+
+// Read loads the results file of the run folder dir.
+func Read(dir string) (Results, error) {
+	var r Results
+	tmp, err := os.ReadFile(filepath.Join(dir, ResultsFile))
+	if err != nil {
+		return r, err
+	}
+	err = json.Unmarshal(tmp, &r)
+	return r, err
+}
+
+// ListBenchmarks returns all benchmarks below root, oldest first.
+func ListBenchmarks(root string) ([]Benchmark, error) {
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var benchmarks []Benchmark
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		path := filepath.Join(root, e.Name())
+		runs, err := ListRuns(path)
+		if err != nil {
+			return nil, err
+		}
+		benchmarks = append(benchmarks, Benchmark{Name: e.Name(), Path: path, Runs: len(runs)})
+	}
+	return benchmarks, nil
+}
+
+// ListRuns returns all runs of the benchmark folder path ordered by their
+// index. Runs are taken from the index file, benchmarks written without an
+// index are scanned for runNNN folders.
+func ListRuns(path string) ([]Run, error) {
+	runs, err := indexedRuns(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		runs, err = scanRuns(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range runs {
+		err = readRun(&runs[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Index < runs[j].Index })
+	return runs, nil
+}
+
+func indexedRuns(path string) ([]Run, error) {
+	idx, err := ReadIndex(path)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]Run, 0, len(idx.Runs))
+	for _, e := range idx.Runs {
+		runs = append(runs, Run{Name: e.Path, Index: e.Run, Path: filepath.Join(path, filepath.FromSlash(e.Path)), Matching: e.Matching})
+	}
+	return runs, nil
+}
+
+func scanRuns(path string) ([]Run, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var runs []Run
+	for _, e := range entries {
+		m := runPattern.FindStringSubmatch(e.Name())
+		if !e.IsDir() || m == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(m[1])
+		runs = append(runs, Run{Name: e.Name(), Index: index, Path: filepath.Join(path, e.Name())})
+	}
+	return runs, nil
+}
+
+// readRun completes the run from the files in its folder.
+func readRun(run *Run) error {
+	r, err := Read(run.Path)
+	if err == nil {
+		run.Matching = r.Matching
+		run.Complete = true
+		run.Status = r.Status
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s: %w", run.Path, err)
+	} else if m, err := ReadManifest(run.Path); err == nil {
+		run.Matching = m.Matching
+	}
+
+	run.Incomplete, err = exists(filepath.Join(run.Path, IncompleteFile))
+	if err != nil {
+		return err
+	}
+	run.Aborted, err = exists(filepath.Join(run.Path, AbortedFile))
+	return err
+}
+
+// FindRun returns the run of the benchmark folder path with the given name or index.
+func FindRun(path string, name string) (Run, error) {
+	runs, err := ListRuns(path)
+	if err != nil {
+		return Run{}, err
+	}
+	for _, run := range runs {
+		if run.Name == name || strconv.Itoa(run.Index) == name {
+			return run, nil
+		}
+	}
+	return Run{}, fs.ErrNotExist
+}
+
+// Matches reports whether the run satisfies every workload filter. A filter
+// value matches the assignment of the workload either completely or one of
+// its dash separated parts, e.g. "Jetson" matches "Jetson-10.0.0.2-l4t".
+func (r Run) Matches(filter map[string]string) bool {
+	for workload, want := range filter {
+		got, ok := r.Matching[workload]
+		if !ok {
+			return false
+		}
+		if got == want {
+			continue
+		}
+		if !strings.HasPrefix(got, want+"-") && !strings.HasSuffix(got, "-"+want) && !strings.Contains(got, "-"+want+"-") {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidName reports whether name can safely be used as a single path element below a result folder.
+func ValidName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
@@ -0,0 +1,62 @@
+package results
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestListRuns(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"run002", "run001", "run010", "plots"} {
+		err := os.Mkdir(filepath.Join(root, dir), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := Write(filepath.Join(root, "run001"), Results{Matching: map[string]string{"Detection": "Jetson-10.0.0.2-l4t"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := ListRuns(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Run{
+		{Name: "run001", Index: 1, Path: filepath.Join(root, "run001"), Matching: map[string]string{"Detection": "Jetson-10.0.0.2-l4t"}, Complete: true},
+		{Name: "run002", Index: 2, Path: filepath.Join(root, "run002")},
+		{Name: "run010", Index: 10, Path: filepath.Join(root, "run010")},
+	}
+	if !reflect.DeepEqual(expected, runs) {
+		t.Fatalf("expected: %v, got: %v", expected, runs)
+	}
+}
+
+func TestRunMatches(t *testing.T) {
+	type testCase struct {
+		filter map[string]string
+		out    bool
+	}
+	run := Run{Matching: map[string]string{"Detection": "Jetson-10.0.0.2-l4t", "Tracking": "APU-10.0.0.3-cpu"}}
+	tests := map[string]testCase{
+		"empty":     {filter: map[string]string{}, out: true},
+		"group":     {filter: map[string]string{"Detection": "Jetson"}, out: true},
+		"node":      {filter: map[string]string{"Detection": "10.0.0.2"}, out: true},
+		"tag":       {filter: map[string]string{"Detection": "l4t", "Tracking": "cpu"}, out: true},
+		"exact":     {filter: map[string]string{"Tracking": "APU-10.0.0.3-cpu"}, out: true},
+		"partial":   {filter: map[string]string{"Detection": "Jet"}, out: false},
+		"mismatch":  {filter: map[string]string{"Tracking": "Jetson"}, out: false},
+		"no-workld": {filter: map[string]string{"Source": "PI4"}, out: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if out := run.Matches(tc.filter); out != tc.out {
+				t.Fatalf("expected: %v, got: %v", tc.out, out)
+			}
+		})
+	}
+}
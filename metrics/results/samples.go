@@ -0,0 +1,96 @@
+package results
+
+import (
+	"encoding/csv"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/measurement"
+)
+
+// RunConfig returns the configuration recorded in the manifest of the run
+// folder dir, or cfg for runs written without manifest.
+func RunConfig(dir string, cfg config.Config) config.Config {
+	m, err := ReadManifest(dir)
+	if err != nil || len(m.Config.Endpoints) == 0 {
+		return cfg
+	}
+	return m.Config
+}
+
+// ReadSamples reads the raw values of every field of the endpoint which is
+// aggregated as metric from the first output file of the run folder dir.
+// Fields are returned in the configured order. The samples of a chain are
+// read from the outputs of its stages.
+func ReadSamples(dir string, endpoint config.EndpointConfig) ([]string, map[string][]float64, error) {
+	if len(endpoint.Stages) > 0 {
+		var fields []string
+		samples := make(map[string][]float64)
+		for _, s := range endpoint.Stages {
+			stage := config.EndpointConfig{Header: s.Header, Fields: s.Fields, Outputs: s.Outputs, Metrics: s.Metrics}
+			f, v, err := ReadSamples(dir, stage)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, name := range f {
+				if _, ok := samples[name]; !ok {
+					fields = append(fields, name)
+					samples[name] = v[name]
+				}
+			}
+		}
+		return fields, samples, nil
+	}
+
+	if len(endpoint.Outputs) == 0 {
+		return nil, nil, nil
+	}
+
+	f, err := os.Open(filepath.Join(dir, endpoint.Outputs[0]))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if endpoint.Header && len(rows) > 0 {
+		rows = rows[1:]
+	}
+
+	var fields []string
+	samples := make(map[string][]float64)
+	for i, name := range endpoint.Fields {
+		if _, ok := endpoint.Metrics[name]; !ok || strings.HasPrefix(name, measurement.FieldPrefix) {
+			continue
+		}
+
+		values := make([]float64, 0, len(rows))
+		for _, row := range rows {
+			if i >= len(row) {
+				continue
+			}
+			v, err := strconv.ParseFloat(row[i], 64)
+			if err == nil {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		fields = append(fields, name)
+		samples[name] = values
+	}
+	return fields, samples, nil
+}
@@ -0,0 +1,90 @@
+package results
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// SessionFile persists the state of the benchmark session writing to a benchmark folder.
+	SessionFile = "session.json"
+	// IncompleteFile marks runs which were interrupted before they ended.
+	IncompleteFile = "incomplete.json"
+	// AbortedFile marks runs which were aborted by the orchestrator.
+	AbortedFile = "aborted.json"
+)
+
+// SessionState is the persisted state of a benchmark session.
+type SessionState struct {
+	ID      string     `json:"id"`
+	Name    string     `json:"name,omitempty"`
+	Started time.Time  `json:"started"`
+	Run     int        `json:"run"`
+	// Path is the folder of the last started run relative to the benchmark
+	// folder, so an interrupted run can still be ended after a restart.
+	Path  string     `json:"path,omitempty"`
+	Ended *time.Time `json:"ended,omitempty"`
+}
+
+// Incomplete explains why a run was marked incomplete or aborted.
+type Incomplete struct {
+	Reason string    `json:"reason"`
+	Marked time.Time `json:"marked"`
+}
+
+// WriteSession stores the session state in the benchmark folder dir.
+func WriteSession(dir string, st SessionState) error {
+	tmp, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Replace the file atomically to not lose the state on a crash while writing.
+	path := filepath.Join(dir, SessionFile)
+	err = os.WriteFile(path+".tmp", tmp, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// ReadSession loads the session state of the benchmark folder dir.
+func ReadSession(dir string) (SessionState, error) {
+	var st SessionState
+	tmp, err := os.ReadFile(filepath.Join(dir, SessionFile))
+	if err != nil {
+		return st, err
+	}
+	err = json.Unmarshal(tmp, &st)
+	return st, err
+}
+
+// MarkIncomplete marks the run folder dir as interrupted.
+func MarkIncomplete(dir string, reason string) error {
+	return mark(dir, IncompleteFile, reason)
+}
+
+// MarkAborted marks the run folder dir as aborted.
+func MarkAborted(dir string, reason string) error {
+	return mark(dir, AbortedFile, reason)
+}
+
+func mark(dir string, file string, reason string) error {
+	tmp, err := json.MarshalIndent(Incomplete{Reason: reason, Marked: time.Now()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, file), tmp, 0600)
+}
+
+func exists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,74 @@
+package results
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotDir holds the snapshots of a run folder.
+const SnapshotDir = "snapshots"
+
+// Snapshot holds the metrics aggregated over the measurements of a run
+// received until Time, while the run continued.
+type Snapshot struct {
+	Time      time.Time                     `json:"time"`
+	Elapsed   float64                       `json:"elapsedSeconds"`
+	Run       int                           `json:"run"`
+	Matching  map[string]string             `json:"matching"`
+	Endpoints map[string]EndpointStatus     `json:"endpoints,omitempty"`
+	Results   map[string]map[string]float64 `json:"results"`
+}
+
+// WriteSnapshot stores s in the snapshot folder of the run folder dir. The
+// file is named by the time of the snapshot.
+func WriteSnapshot(dir string, s Snapshot) ([]byte, error) {
+	tmp, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, SnapshotDir)
+	err = os.MkdirAll(path, 0755)
+	if err != nil {
+		return nil, err
+	}
+	name := s.Time.UTC().Format("20060102T150405.000Z") + ".json"
+	return tmp, os.WriteFile(filepath.Join(path, name), tmp, 0600)
+}
+
+// ReadSnapshots loads all snapshots of the run folder dir, oldest first.
+func ReadSnapshots(dir string) ([]Snapshot, error) {
+	path := filepath.Join(dir, SnapshotDir)
+	entries, err := os.ReadDir(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		tmp, err := os.ReadFile(filepath.Join(path, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var s Snapshot
+		err = json.Unmarshal(tmp, &s)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Time.Before(snapshots[j].Time) })
+	return snapshots, nil
+}
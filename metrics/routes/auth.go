@@ -0,0 +1,67 @@
+package routes
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/sbaeurle/comb/metrics/config"
+)
+
+// TokenAuth only admits requests carrying one of the given bearer tokens.
+// Without any configured token all requests are passed through.
+func TokenAuth(log config.Logger, tokens []string) mux.MiddlewareFunc {
+	return tokenAuth(log, tokens, false)
+}
+
+// StreamTokenAuth is TokenAuth for event streams, which also accepts the
+// token in the access_token query parameter, as the EventSource of browsers
+// cannot set headers.
+func StreamTokenAuth(log config.Logger, tokens []string) mux.MiddlewareFunc {
+	return tokenAuth(log, tokens, true)
+}
+
+func tokenAuth(log config.Logger, tokens []string, query bool) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if len(tokens) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := bearerToken(r)
+			if token == "" && query {
+				token = r.URL.Query().Get("access_token")
+			}
+			if !validToken(token, tokens) {
+				log.Warnf("rejected unauthorized request from %s to %s", r.RemoteAddr, r.URL.Path)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="comb"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bearerToken reads the token from the Authorization header.
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
+
+func validToken(token string, tokens []string) bool {
+	if token == "" {
+		return false
+	}
+
+	// Compare against every token to not leak which one matched.
+	valid := 0
+	for _, t := range tokens {
+		valid |= subtle.ConstantTimeCompare([]byte(token), []byte(t))
+	}
+	return valid == 1
+}
@@ -0,0 +1,95 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mock_config "github.com/sbaeurle/comb/metrics/config/mocks"
+)
+
+func TestTokenAuth(t *testing.T) {
+	type testCase struct {
+		tokens []string
+		header string
+		query  string
+		stream bool
+		status int
+	}
+	tests := map[string]testCase{
+		"disabled": {
+			status: http.StatusOK,
+		},
+		"valid": {
+			tokens: []string{"a", "b"},
+			header: "Bearer b",
+			status: http.StatusOK,
+		},
+		"lowercase-scheme": {
+			tokens: []string{"a"},
+			header: "bearer a",
+			status: http.StatusOK,
+		},
+		"invalid": {
+			tokens: []string{"a"},
+			header: "Bearer c",
+			status: http.StatusUnauthorized,
+		},
+		"missing": {
+			tokens: []string{"a"},
+			status: http.StatusUnauthorized,
+		},
+		"basic-auth": {
+			tokens: []string{"a"},
+			header: "Basic a",
+			status: http.StatusUnauthorized,
+		},
+		"query": {
+			tokens: []string{"a"},
+			query:  "a",
+			status: http.StatusUnauthorized,
+		},
+		"stream-query": {
+			tokens: []string{"a"},
+			query:  "a",
+			stream: true,
+			status: http.StatusOK,
+		},
+		"stream-invalid-query": {
+			tokens: []string{"a"},
+			query:  "c",
+			stream: true,
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockLogger := mock_config.NewMockLogger(mockCtrl)
+			mockLogger.EXPECT().Warnf(gomock.Any(), gomock.Any()).AnyTimes()
+
+			auth := TokenAuth
+			if tc.stream {
+				auth = StreamTokenAuth
+			}
+			handler := auth(mockLogger, tc.tokens)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("POST", "/start-run?access_token="+tc.query, nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("expected: %v, got: %v", tc.status, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sbaeurle/comb/metrics/clock"
+)
+
+// ClockReportURL is the url of relayed clock reports, see ClockService.Report.
+const ClockReportURL = "/clock/report"
+
+// DefaultDrainTimeout is how long /end-run waits for relays by default.
+const DefaultDrainTimeout = 10 * time.Second
+
+// BatchSample is a measurement forwarded by a relay. It keeps the time the
+// relay received it and the headers describing its source. Session and Run
+// are the session and run the relay knew of when it received the
+// measurement, if any.
+type BatchSample struct {
+	Session  string            `json:"session,omitempty"`
+	Run      int               `json:"run,omitempty"`
+	Url      string            `json:"url"`
+	Received time.Time         `json:"received"`
+	Address  string            `json:"address,omitempty"`
+	Header   map[string]string `json:"header,omitempty"`
+	Body     json.RawMessage   `json:"body"`
+}
+
+// Batch is the body of /ingest-batch. Sent is the time the batch was sent by
+// the clock of its sender, the receive times of its samples are shifted by
+// the difference to the clock of the metric service.
+//
+// Relays post a batch every interval, empty ones if there is nothing to
+// forward. Backlog counts the batches the relay still has to forward, if
+// there are none the relay forwarded every measurement it received before
+// Complete.
+type Batch struct {
+	Relay    string        `json:"relay,omitempty"`
+	Sent     time.Time     `json:"sent,omitempty"`
+	Backlog  int           `json:"backlog,omitempty"`
+	Complete time.Time     `json:"complete,omitempty"`
+	Samples  []BatchSample `json:"samples"`
+}
+
+// BatchResponse counts the samples of a batch which were accepted and which
+// were dropped, e.g. because their session ended or their run was over.
+// Latest is the session unscoped measurements are ingested into, Runs the
+// current run of every session. Relays tag the measurements they receive
+// with them.
+type BatchResponse struct {
+	Accepted int            `json:"accepted"`
+	Dropped  int            `json:"dropped"`
+	Latest   string         `json:"latest,omitempty"`
+	Runs     map[string]int `json:"runs,omitempty"`
+}
+
+// relayState is the last report of a relay by the clock of the metric
+// service.
+type relayState struct {
+	seen     time.Time
+	backlog  int
+	complete time.Time
+}
+
+// SourceHeaders lists the headers relays forward with a measurement.
+var SourceHeaders = []string{HeaderSourceToken, HeaderNode, HeaderWorkload, HeaderContainer}
+
+// IngestBatch ingests measurements forwarded by a relay with their original
+// receive time. Samples which cannot be ingested are dropped and counted, so
+// relays do not send them again.
+func (cs *ControlService) IngestBatch(w http.ResponseWriter, r *http.Request) {
+	var batch Batch
+	err := json.NewDecoder(r.Body).Decode(&batch)
+	if err != nil {
+		cs.log.Error(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var skew time.Duration
+	if !batch.Sent.IsZero() {
+		skew = time.Since(batch.Sent)
+	}
+
+	var resp BatchResponse
+	reasons := make(map[string]int)
+	for _, sample := range batch.Samples {
+		sample.Received = sample.Received.Add(skew)
+		err := cs.ingestSample(r, sample)
+		if err != nil {
+			cs.log.Debugf("Dropped sample of relay %s for %s: %v", batch.Relay, sample.Url, err)
+			reasons[err.Error()]++
+			resp.Dropped++
+			continue
+		}
+		resp.Accepted++
+	}
+	if resp.Dropped > 0 {
+		cs.log.Warnf("Dropped %d of %d samples forwarded by relay %s: %v", resp.Dropped, len(batch.Samples), batch.Relay, reasons)
+	}
+
+	if batch.Relay != "" {
+		st := relayState{seen: time.Now(), backlog: batch.Backlog}
+		if !batch.Complete.IsZero() {
+			st.complete = batch.Complete.Add(skew)
+		}
+		cs.reportRelay(batch.Relay, st)
+	}
+
+	resp.Latest, resp.Runs = cs.runs()
+	writeJSON(w, resp)
+}
+
+// ingestSample ingests a sample into the session it was posted to, or the
+// session addressed by the batch request.
+func (cs *ControlService) ingestSample(r *http.Request, sample BatchSample) error {
+	cs.mu.RLock()
+	id := sample.Session
+	if id == "" {
+		id = r.Header.Get(HeaderSession)
+	}
+	if id == "" {
+		id = cs.latest
+	}
+	s, ok := cs.sessions[id]
+	cs.mu.RUnlock()
+	if !ok {
+		return errSessionClosed
+	}
+
+	header := make(http.Header)
+	for k, v := range sample.Header {
+		header.Set(k, v)
+	}
+	src := s.sources.ResolveHeader(header, sample.Address)
+	if sample.Url == ClockReportURL {
+		var ex clock.Exchange
+		err := json.Unmarshal(sample.Body, &ex)
+		if err != nil {
+			return err
+		}
+		return s.addExchange(src.Node, ex)
+	}
+	return s.ingest(sample.Url, src, sample.Body, sample.Received, sample.Run)
+}
+
+// reportRelay records the report of a relay and wakes up drainRelays.
+func (cs *ControlService) reportRelay(name string, st relayState) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.relays[name] = st
+	close(cs.relayReport)
+	cs.relayReport = make(chan struct{})
+}
+
+// runs returns the latest session and the current run of every session.
+func (cs *ControlService) runs() (string, map[string]int) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	runs := make(map[string]int)
+	for id, s := range cs.sessions {
+		if run := s.currentRun(); run > 0 {
+			runs[id] = run
+		}
+	}
+	return cs.latest, runs
+}
+
+// drainRelays waits until the relays forwarded every measurement they
+// received before the run of s ends, at most Relay.DrainTimeout. Relays are
+// waited for if they reported during the run or still have a backlog.
+func (cs *ControlService) drainRelays(s *Session) {
+	start, ok := s.runStart()
+	if !ok {
+		return
+	}
+	end := time.Now()
+
+	cs.mu.RLock()
+	timeout := cs.current.cfg.Relay.DrainTimeout
+	cs.mu.RUnlock()
+	if timeout == 0 {
+		timeout = DefaultDrainTimeout
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		cs.mu.RLock()
+		var pending []string
+		for name, st := range cs.relays {
+			if (st.seen.After(start) || st.backlog > 0) && (st.backlog > 0 || st.complete.Before(end)) {
+				pending = append(pending, name)
+			}
+		}
+		report := cs.relayReport
+		cs.mu.RUnlock()
+		if len(pending) == 0 {
+			return
+		}
+
+		select {
+		case <-report:
+		case <-deadline.C:
+			sort.Strings(pending)
+			cs.log.Warnf("Ending run of session %s without the measurements relays %s did not forward within %v", s.ID, strings.Join(pending, ", "), timeout)
+			return
+		}
+	}
+}
+
+// currentRun returns the run measurements are ingested into, 0 if no run was
+// started.
+func (s *Session) currentRun() int {
+	s.lifecycle.RLock()
+	defer s.lifecycle.RUnlock()
+	if s.stats == nil {
+		return 0
+	}
+	return s.stats.run
+}
+
+// runStart returns the start of the current run, false if no run is running.
+func (s *Session) runStart() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path == "" || s.stats == nil {
+		return time.Time{}, false
+	}
+	return s.stats.start, true
+}
@@ -0,0 +1,151 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sbaeurle/comb/metrics/clock"
+	"github.com/sbaeurle/comb/metrics/results"
+)
+
+func postBatch(t *testing.T, url string, batch Batch) BatchResponse {
+	resp := post(t, url+"/ingest-batch", batch)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	var out BatchResponse
+	err := json.NewDecoder(resp.Body).Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestIngestBatch(t *testing.T) {
+	srv := newTestServer(t, newTestConfig(t))
+	info := startSession(t, srv)
+
+	before := time.Now()
+	if resp := post(t, srv.URL+"/start-run", map[string]string{"WL": "a"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+
+	now := time.Now().UnixNano()
+	ex, err := json.Marshal(clock.Exchange{Origin: now - 40, Receive: now - 20, Transmit: now - 10, Destination: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := Batch{
+		Samples: []BatchSample{
+			{Url: "/test", Received: time.Now(), Address: "10.0.0.2:4242", Header: map[string]string{HeaderNode: "jetson"}, Body: json.RawMessage(`{"value": 2}`)},
+			{Session: info.ID, Url: "/test", Received: time.Now(), Body: json.RawMessage(`{"value": 4}`)},
+			// Received before the run started, the relay did not know of it.
+			{Url: "/test", Received: before.Add(-time.Second), Body: json.RawMessage(`{"value": 100}`)},
+			{Session: info.ID, Run: 1, Url: "/test", Received: before.Add(-time.Second), Body: json.RawMessage(`{"value": 3}`)},
+			{Session: info.ID, Run: 2, Url: "/test", Received: time.Now(), Body: json.RawMessage(`{"value": 100}`)},
+			{Url: "/unknown", Received: time.Now(), Body: json.RawMessage(`{"value": 100}`)},
+			{Session: "unknown", Url: "/test", Received: time.Now(), Body: json.RawMessage(`{"value": 100}`)},
+			{Url: ClockReportURL, Received: time.Now(), Header: map[string]string{HeaderNode: "jetson"}, Body: ex},
+		},
+	}
+	out := postBatch(t, srv.URL, batch)
+	expected := BatchResponse{Accepted: 4, Dropped: 4, Latest: info.ID, Runs: map[string]int{info.ID: 1}}
+	if !reflect.DeepEqual(out, expected) {
+		t.Fatalf("expected: %v, got: %v", expected, out)
+	}
+
+	// The clock of the sender is an hour behind, the sample is shifted into the run.
+	sent := time.Now().Add(-time.Hour)
+	late := Batch{Sent: sent, Samples: []BatchSample{{Url: "/test", Received: sent, Body: json.RawMessage(`{"value": 3}`)}}}
+	out = postBatch(t, srv.URL, late)
+	if out.Accepted != 1 || out.Dropped != 0 {
+		t.Fatalf("expected: %v, got: %v", 1, out)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if resp := post(t, srv.URL+"/end-run", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	res, err := results.Read(filepath.Join(info.Root, "run001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Results["test"]["value-AVG"] != 3.0 {
+		t.Fatalf("expected: %v, got: %v", 3.0, res.Results["test"]["value-AVG"])
+	}
+	if res.Endpoints["test"].Samples != 4 {
+		t.Fatalf("expected: %v, got: %v", 4, res.Endpoints["test"].Samples)
+	}
+	if _, ok := res.Clocks["jetson"]; !ok {
+		t.Fatalf("expected: %v, got: %v", "jetson", res.Clocks)
+	}
+
+	if resp := post(t, srv.URL+"/ingest-batch", "no batch"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected: %v, got: %v", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestDrainRelays(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Relay.DrainTimeout = 500 * time.Millisecond
+	srv := newTestServer(t, cfg)
+	startSession(t, srv)
+	if resp := post(t, srv.URL+"/start-run", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+
+	// Site a still has a batch to forward, site b forwarded everything.
+	postBatch(t, srv.URL, Batch{Relay: "site-a", Backlog: 1, Complete: time.Now()})
+	postBatch(t, srv.URL, Batch{Relay: "site-b", Complete: time.Now().Add(time.Minute)})
+
+	ended := make(chan int)
+	go func() {
+		resp, err := http.Post(srv.URL+"/end-run", "application/json", nil)
+		if err != nil {
+			ended <- 0
+			return
+		}
+		resp.Body.Close()
+		ended <- resp.StatusCode
+	}()
+
+	select {
+	case status := <-ended:
+		t.Fatalf("expected: %v, got: %v", "waiting for site-a", status)
+	case <-time.After(100 * time.Millisecond):
+	}
+	out := postBatch(t, srv.URL, Batch{
+		Relay:    "site-a",
+		Complete: time.Now(),
+		Samples:  []BatchSample{{Run: 1, Url: "/test", Received: time.Now(), Body: json.RawMessage(`{"value": 1}`)}},
+	})
+	if out.Accepted != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, out.Accepted)
+	}
+	select {
+	case status := <-ended:
+		if status != http.StatusOK {
+			t.Fatalf("expected: %v, got: %v", http.StatusOK, status)
+		}
+	case <-time.After(200 * time.Millisecond):
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, "waiting")
+	}
+
+	// Relays which do not report hold back the end of a run up to DrainTimeout.
+	startSession(t, srv)
+	if resp := post(t, srv.URL+"/start-run", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	postBatch(t, srv.URL, Batch{Relay: "site-a", Backlog: 3})
+	start := time.Now()
+	if resp := post(t, srv.URL+"/end-run", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	if waited := time.Since(start); waited < cfg.Relay.DrainTimeout {
+		t.Fatalf("expected: %v, got: %v", cfg.Relay.DrainTimeout, waited)
+	}
+}
@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sbaeurle/comb/lib/client"
+)
+
+// TestClient runs the client against the routes of the metric service.
+func TestClient(t *testing.T) {
+	srv := newTestServer(t, newTestConfig(t))
+	c, err := client.New(srv.URL+"/", client.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, info, err := c.StartBenchmark("client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.URL() != srv.URL+"/sessions/"+info.ID {
+		t.Fatalf("expected: %v, got: %v", srv.URL+"/sessions/"+info.ID, session.URL())
+	}
+	_, err = session.EndRun()
+	if !client.IsStatus(err, http.StatusConflict) {
+		t.Fatalf("expected: %v, got: %v", http.StatusConflict, err)
+	}
+
+	source := client.Source{Node: "jetson", Workload: "tracking"}
+	err = session.StartRun(client.RunRequest{
+		Matching: map[string]string{"tracking": "jetson"},
+		Sources:  map[string]client.Source{"token": source},
+		Manifest: map[string]string{"version": "test"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	workload, err := client.New(session.URL(), client.Options{SourceToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = workload.Send("/test", map[string]float64{"value": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = workload.Send("/unknown", map[string]float64{"value": 1})
+	if !client.IsStatus(err, http.StatusNotFound) {
+		t.Fatalf("expected: %v, got: %v", http.StatusNotFound, err)
+	}
+
+	// Batches are sent once full and on close.
+	b := session.NewBatcher(2, 0)
+	for _, v := range []float64{2, 3, 6} {
+		err = b.Add("/test", map[string]float64{"value": v})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = b.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := session.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Endpoints["test"].Samples != 4 {
+		t.Fatalf("expected: %v, got: %v", 4, snapshot.Endpoints["test"].Samples)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	res, err := session.EndRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != client.StatusOK {
+		t.Fatalf("expected: %v, got: %v", client.StatusOK, res.Status)
+	}
+	if res.Results["test"]["value-AVG"] != 3.0 {
+		t.Fatalf("expected: %v, got: %v", 3.0, res.Results["test"]["value-AVG"])
+	}
+
+	sessions, err := c.Sessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || len(sessions[0].Completed()) != 1 {
+		t.Fatalf("expected: %v, got: %v", 1, sessions)
+	}
+
+	err = session.EndBenchmark()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.GetSession(info.ID)
+	if !client.IsStatus(err, http.StatusNotFound) {
+		t.Fatalf("expected: %v, got: %v", http.StatusNotFound, err)
+	}
+}
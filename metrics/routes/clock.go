@@ -0,0 +1,125 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sbaeurle/comb/metrics/clock"
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/measurement"
+)
+
+// ClockService offers NTP-style time exchanges for nodes to estimate their
+// clock offset. Estimates are kept per benchmark session.
+type ClockService struct {
+	log      config.Logger
+	sessions *ControlService
+}
+
+func NewClockService(log config.Logger, sessions *ControlService) *ClockService {
+	return &ClockService{log: log, sessions: sessions}
+}
+
+// RegisterClockRoutes adds the time exchange routes with and without session scope to r.
+func (c *ClockService) RegisterClockRoutes(r *mux.Router) {
+	for _, prefix := range []string{"", "/sessions/{session}"} {
+		r.HandleFunc(prefix+"/clock/sync", c.Sync).Methods("POST")
+		r.HandleFunc(prefix+"/clock/report", c.Report).Methods("POST")
+	}
+}
+
+// Sync answers a time request of a node with the receive and transmit time of the metric service.
+func (c *ClockService) Sync(w http.ResponseWriter, r *http.Request) {
+	receive := time.Now().UnixNano()
+
+	var ex clock.Exchange
+	err := json.NewDecoder(r.Body).Decode(&ex)
+	if err != nil {
+		c.log.Error(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ex.Receive = receive
+	ex.Destination = 0
+
+	w.Header().Set("Content-Type", "application/json")
+	ex.Transmit = time.Now().UnixNano()
+	json.NewEncoder(w).Encode(ex)
+}
+
+// Report receives a completed time exchange from a node.
+func (c *ClockService) Report(w http.ResponseWriter, r *http.Request) {
+	s, ok := c.sessions.session(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var ex clock.Exchange
+	err := json.NewDecoder(r.Body).Decode(&ex)
+	if err != nil {
+		c.log.Error(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = s.addExchange(s.sources.Resolve(r).Node, ex)
+	if err != nil {
+		c.log.Warnf("discarding %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// addExchange adds a completed time exchange of node to the clock estimates
+// of the session.
+func (s *Session) addExchange(node string, ex clock.Exchange) error {
+	if ex.Origin == 0 || ex.Receive == 0 || ex.Transmit == 0 || ex.Destination == 0 || ex.Delay() < 0 {
+		return fmt.Errorf("invalid time exchange %+v", ex)
+	}
+	s.clocks.Add(node, ex)
+	return nil
+}
+
+// correctTimestamps shifts the given timestamp fields of a measurement from
+// the node clock to the clock of the metric service. Integer timestamps are
+// shifted in nanoseconds, so nanosecond timestamps keep their precision.
+func correctTimestamps(body []byte, fields []string, unit time.Duration, offset time.Duration) ([]byte, error) {
+	var tmp map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	err := dec.Decode(&tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range fields {
+		n, ok := tmp[f].(json.Number)
+		if !ok {
+			continue
+		}
+		if i, err := n.Int64(); err == nil {
+			shifted := time.Duration(i)*unit + offset
+			if shifted%unit == 0 {
+				tmp[f] = int64(shifted / unit)
+			} else {
+				tmp[f] = float64(shifted) / float64(unit)
+			}
+		} else if v, err := n.Float64(); err == nil {
+			tmp[f] = v + float64(offset)/float64(unit)
+		}
+	}
+	return json.Marshal(tmp)
+}
+
+func timestampUnit(endpoint config.EndpointConfig) (time.Duration, error) {
+	unit, ok := measurement.TimestampUnits[endpoint.TimestampUnit]
+	if !ok {
+		return 0, fmt.Errorf("%s: unknown timestamp unit %s", endpoint.Name, endpoint.TimestampUnit)
+	}
+	return unit, nil
+}
@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCorrectTimestamps(t *testing.T) {
+	type testCase struct {
+		body     string
+		unit     time.Duration
+		offset   time.Duration
+		expected string
+	}
+	tests := map[string]testCase{
+		"nanoseconds":  {body: `{"ts":1654041600123456789}`, unit: time.Nanosecond, offset: -1001, expected: `{"ts":1654041600123455788}`},
+		"milliseconds": {body: `{"ts":1654041600123}`, unit: time.Millisecond, offset: 2 * time.Millisecond, expected: `{"ts":1654041600125}`},
+		"sub-unit":     {body: `{"ts":1000}`, unit: time.Millisecond, offset: 500 * time.Microsecond, expected: `{"ts":1000.5}`},
+		"fraction":     {body: `{"ts":1654041600.5}`, unit: time.Second, offset: time.Second, expected: `{"ts":1654041601.5}`},
+		"other fields": {body: `{"id":12345678901234567,"name":"a","ts":1}`, unit: time.Second, offset: time.Second, expected: `{"id":12345678901234567,"name":"a","ts":2}`},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			out, err := correctTimestamps([]byte(tc.body), []string{"ts"}, tc.unit, tc.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tc.expected {
+				t.Fatalf("expected: %v, got: %v", tc.expected, string(out))
+			}
+		})
+	}
+}
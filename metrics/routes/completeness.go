@@ -0,0 +1,132 @@
+package routes
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/results"
+)
+
+// runStats tracks the measurements of every endpoint during a run to check
+// their completeness when the run ends.
+type runStats struct {
+	mu        sync.Mutex
+	run       int
+	start     time.Time
+	endpoints map[string]*endpointStats
+}
+
+type endpointStats struct {
+	samples int
+	last    time.Time
+	maxGap  time.Duration
+	// fields counts the measurements containing each required field.
+	fields map[string]int
+}
+
+func newRunStats(run int, start time.Time) *runStats {
+	return &runStats{run: run, start: start, endpoints: make(map[string]*endpointStats)}
+}
+
+// accepts reports whether a measurement received at the given time belongs to
+// the run. Relays tag measurements with the run they knew of, which may lag
+// behind the runs of the session, so measurements tagged with an earlier run
+// belong to the run if they were received after it started. Untagged
+// measurements (run 0) are checked by their receive time only.
+func (r *runStats) accepts(run int, received time.Time) bool {
+	switch {
+	case run == r.run:
+		return true
+	case run > r.run:
+		return false
+	}
+	return !received.Before(r.start)
+}
+
+// add counts a measurement of the endpoint with the given fields, see
+// ingestEndpoint.fields.
+func (r *runStats) add(endpoint config.EndpointConfig, values map[string]interface{}, received time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.endpoints[endpoint.Name]
+	if !ok {
+		e = &endpointStats{last: r.start, fields: make(map[string]int)}
+		r.endpoints[endpoint.Name] = e
+	}
+
+	e.samples++
+	if gap := received.Sub(e.last); gap > e.maxGap {
+		e.maxGap = gap
+	}
+	e.last = received
+	for _, field := range endpoint.Completeness.RequiredFields {
+		if _, ok := values[field]; ok {
+			e.fields[field]++
+		}
+	}
+}
+
+// check applies the completeness rules of every endpoint and returns the
+// status of the run, which is the worst status of its endpoints.
+func (r *runStats) check(endpoints []config.EndpointConfig, end time.Time) (string, map[string]results.EndpointStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run := results.StatusOK
+	out := make(map[string]results.EndpointStatus)
+	for _, endpoint := range endpoints {
+		e, ok := r.endpoints[endpoint.Name]
+		if !ok {
+			e = &endpointStats{last: r.start, fields: make(map[string]int)}
+		}
+		maxGap := e.maxGap
+		if gap := end.Sub(e.last); gap > maxGap {
+			maxGap = gap
+		}
+
+		rules := endpoint.Completeness
+		var problems []string
+		if e.samples < rules.MinSamples {
+			problems = append(problems, fmt.Sprintf("%d of %d required samples", e.samples, rules.MinSamples))
+		}
+		if rules.MaxGap > 0 && maxGap > rules.MaxGap {
+			problems = append(problems, fmt.Sprintf("gap of %v exceeds %v", maxGap.Round(time.Millisecond), rules.MaxGap))
+		}
+		for _, field := range rules.RequiredFields {
+			if missing := e.samples - e.fields[field]; missing > 0 {
+				problems = append(problems, fmt.Sprintf("%s missing in %d of %d samples", field, missing, e.samples))
+			}
+		}
+
+		status := results.StatusOK
+		if len(problems) > 0 {
+			status = results.StatusFailed
+			if rules.Severity == results.StatusWarn {
+				status = results.StatusWarn
+			}
+		} else if e.samples == 0 {
+			// Endpoints without rules are not expected to receive data, but it is worth a look.
+			status = results.StatusWarn
+			problems = append(problems, "no samples")
+		}
+
+		out[endpoint.Name] = results.EndpointStatus{
+			Status:   status,
+			Samples:  e.samples,
+			MaxGapMs: float64(maxGap) / float64(time.Millisecond),
+			Problems: problems,
+		}
+		run = worse(run, status)
+	}
+	return run, out
+}
+
+func worse(a, b string) string {
+	rank := map[string]int{results.StatusOK: 0, results.StatusWarn: 1, results.StatusFailed: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
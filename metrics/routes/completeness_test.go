@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/expr"
+	"github.com/sbaeurle/comb/metrics/results"
+)
+
+func TestRunStatsCheck(t *testing.T) {
+	type testCase struct {
+		rules    config.CompletenessConfig
+		derived  map[string]string
+		bodies   []string
+		status   string
+		problems []string
+	}
+	tests := map[string]testCase{
+		"ok":            {rules: config.CompletenessConfig{MinSamples: 2}, bodies: []string{`{"a": 1}`, `{"a": 2}`}, status: results.StatusOK},
+		"no-rules":      {bodies: nil, status: results.StatusWarn, problems: []string{"no samples"}},
+		"min-samples":   {rules: config.CompletenessConfig{MinSamples: 3}, bodies: []string{`{"a": 1}`}, status: results.StatusFailed, problems: []string{"1 of 3 required samples"}},
+		"warn-severity": {rules: config.CompletenessConfig{MinSamples: 3, Severity: "WARN"}, bodies: []string{`{"a": 1}`}, status: results.StatusWarn, problems: []string{"1 of 3 required samples"}},
+		"required":      {rules: config.CompletenessConfig{RequiredFields: []string{"a"}}, bodies: []string{`{"a": 1}`, `{"b": 2}`, `[]`}, status: results.StatusFailed, problems: []string{"a missing in 2 of 3 samples"}},
+		"derived":       {rules: config.CompletenessConfig{RequiredFields: []string{"c"}}, derived: map[string]string{"c": "a * 2"}, bodies: []string{`{"a": 1}`, `{"b": 2}`}, status: results.StatusFailed, problems: []string{"c missing in 1 of 2 samples"}},
+		"gap":           {rules: config.CompletenessConfig{MaxGap: 500 * time.Millisecond}, bodies: []string{`{"a": 1}`}, status: results.StatusFailed, problems: []string{"gap of 1s exceeds 500ms"}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			endpoint := config.EndpointConfig{Name: "test", Completeness: tc.rules, Derived: tc.derived}
+			derived, err := expr.Compile(tc.derived)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ingest := ingestEndpoint{cfg: endpoint, derived: derived}
+			stats := newRunStats(1, start)
+			for i, body := range tc.bodies {
+				stats.add(endpoint, ingest.fields([]byte(body)), start.Add(time.Duration(i+1)*100*time.Millisecond))
+			}
+
+			// The run ends 1s after its last measurement.
+			end := start.Add(time.Duration(len(tc.bodies))*100*time.Millisecond + time.Second)
+			status, endpoints := stats.check([]config.EndpointConfig{endpoint}, end)
+			if status != tc.status || endpoints["test"].Status != tc.status {
+				t.Fatalf("expected: %v, got: %v", tc.status, endpoints["test"])
+			}
+			if !reflect.DeepEqual(tc.problems, endpoints["test"].Problems) {
+				t.Fatalf("expected: %v, got: %v", tc.problems, endpoints["test"].Problems)
+			}
+		})
+	}
+}
@@ -2,142 +2,414 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/sbaeurle/comb/metrics/config"
-	"github.com/sbaeurle/comb/metrics/modules"
+	"github.com/sbaeurle/comb/metrics/measurement"
+	"github.com/sbaeurle/comb/metrics/results"
 )
 
+// HeaderSession selects the benchmark session for routes without session in their path.
+const HeaderSession = "X-Comb-Session"
+
+// ControlService manages the benchmark sessions. Routes without a session
+// ID act on the most recently started session.
 type ControlService struct {
-	log     config.Logger
-	cfg     config.Config
-	modz    map[string]modules.Module
-	mapping map[string]string
-	root    string
-	path    string
-	run     int
+	log      config.Logger
+	load     func() (config.Config, error)
+	mu       sync.RWMutex
+	current  loadedConfig
+	urls     map[string]bool
+	sessions map[string]*Session
+	latest   string
+	// relays holds the last report of every relay, relayReport is closed
+	// and replaced on every report.
+	relays      map[string]relayState
+	relayReport chan struct{}
+}
+
+// runRequest is the body of /start-run. Sources maps the tokens issued by
+// the orchestrator to the workload they were handed to, Manifest is
+// recorded in the manifest of the run.
+type runRequest struct {
+	Matching map[string]string             `json:"matching"`
+	Sources  map[string]measurement.Source `json:"sources"`
+	Manifest json.RawMessage               `json:"manifest"`
+}
+
+// startRequest is the optional body of /start-benchmark. Name is available
+// in the layout of the result folders.
+type startRequest struct {
+	Name string `json:"name"`
+}
+
+// abortRequest is the optional body of /abort-run.
+type abortRequest struct {
+	Reason string `json:"reason"`
 }
 
-func NewControlService(log config.Logger, cfg config.Config, modz map[string]modules.Module) (*ControlService, error) {
-	return &ControlService{log: log, cfg: cfg, modz: modz}, nil
+// resumeRequest is the body of /resume-benchmark. ID is either the ID of a
+// session or the name of a benchmark folder.
+type resumeRequest struct {
+	ID string `json:"id"`
+}
+
+// NewControlService creates the control service and resumes all sessions
+// which did not end before the metric service stopped.
+func NewControlService(log config.Logger, cfg config.Config) (*ControlService, error) {
+	layout, err := results.NewLayout(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cs := &ControlService{
+		log:         log,
+		current:     loadedConfig{cfg: cfg, layout: layout, version: cfg.Version()},
+		urls:        make(map[string]bool),
+		sessions:    make(map[string]*Session),
+		relays:      make(map[string]relayState),
+		relayReport: make(chan struct{}),
+	}
+	cs.addURLs(cfg)
+
+	// Only the latest session which was not ended is resumed, older ones
+	// were likely abandoned and are resumed on request.
+	benchmarks, err := results.ListBenchmarks(cfg.RootFolder)
+	if err != nil {
+		return nil, err
+	}
+	var open []string
+	started := make(map[string]time.Time)
+	for _, b := range benchmarks {
+		st, err := results.ReadSession(b.Path)
+		if errors.Is(err, fs.ErrNotExist) || (err == nil && st.Ended != nil) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", b.Path, err)
+		}
+		open = append(open, b.Path)
+		started[b.Path] = st.Started
+	}
+	if len(open) == 0 {
+		return cs, nil
+	}
+	sort.Slice(open, func(i, j int) bool { return started[open[i]].After(started[open[j]]) })
+	for _, path := range open[1:] {
+		log.Infof("Not resuming benchmark session in %s, resume it with /resume-benchmark", path)
+	}
+
+	s, err := resumeSession(log, cs.current, open[0])
+	if err != nil {
+		return nil, err
+	}
+	cs.sessions[s.ID] = s
+	cs.latest = s.ID
+	log.Infof("Resumed benchmark session %s in %s after run %d", s.ID, s.root, s.run)
+	return cs, nil
+}
+
+// RegisterControlRoutes adds the control routes with and without session scope to r.
+func (cs *ControlService) RegisterControlRoutes(r *mux.Router) {
+	r.HandleFunc("/start-benchmark", cs.StartBenchmark).Methods("POST")
+	r.HandleFunc("/resume-benchmark", cs.ResumeBenchmark).Methods("POST")
+	r.HandleFunc("/reload-config", cs.ReloadConfig).Methods("POST")
+	for _, prefix := range []string{"", "/sessions/{session}"} {
+		r.HandleFunc(prefix+"/end-benchmark", cs.EndBenchmark).Methods("POST")
+		r.HandleFunc(prefix+"/start-run", cs.StartRun).Methods("POST")
+		r.HandleFunc(prefix+"/end-run", cs.EndRun).Methods("POST")
+		r.HandleFunc(prefix+"/abort-run", cs.AbortRun).Methods("POST")
+		r.HandleFunc(prefix+"/snapshot", cs.Snapshot).Methods("POST")
+	}
+	r.HandleFunc("/sessions", cs.ListSessions).Methods("GET")
+	r.HandleFunc("/sessions/{session}", cs.GetSession).Methods("GET")
+}
+
+// session returns the session addressed by the request.
+func (cs *ControlService) session(r *http.Request) (*Session, bool) {
+	id, ok := mux.Vars(r)["session"]
+	if !ok {
+		id = r.Header.Get(HeaderSession)
+	}
+
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	if id == "" {
+		id = cs.latest
+	}
+	s, ok := cs.sessions[id]
+	return s, ok
 }
 
 func (cs *ControlService) StartRun(w http.ResponseWriter, r *http.Request) {
-	var tmp map[string]string
-	err := json.NewDecoder(r.Body).Decode(&tmp)
+	s, ok := cs.session(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		cs.log.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	cs.mapping = tmp
-	cs.run++
 
-	cs.path, err = filepath.Abs(fmt.Sprintf("%s/run%03d", cs.root, cs.run))
+	req, err := decodeRunRequest(body)
 	if err != nil {
 		cs.log.Error(err)
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	err = os.Mkdir(cs.path, 0755)
+	err = s.startRun(req)
 	if err != nil {
 		cs.log.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	for _, m := range cs.modz {
-		err = m.StartMeasurement(cs.path)
-		if err != nil {
-			cs.log.Error(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-	}
-
 	w.WriteHeader(http.StatusOK)
 }
 
 func (cs *ControlService) EndRun(w http.ResponseWriter, r *http.Request) {
-	results := make(map[string]map[string]float64)
-	for k, m := range cs.modz {
-		tmp, err := m.CollectMetrics()
-		if err != nil {
-			cs.log.Error(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
+	s, ok := cs.session(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
 
-		results[k] = tmp
+	// Relays may not have forwarded all measurements of the run yet.
+	cs.drainRelays(s)
+	tmp, err := s.endRun()
+	if err == errNoRun {
+		w.WriteHeader(http.StatusConflict)
+		return
+	} else if err != nil {
+		cs.log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
-	output := struct {
-		Matching map[string]string             `json:"matching"`
-		Results  map[string]map[string]float64 `json:"results"`
-	}{
-		Matching: cs.mapping,
-		Results:  results,
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(tmp)
+}
+
+// Snapshot aggregates the metrics of the current run so far without ending
+// the run. The snapshot is also stored in the run folder.
+func (cs *ControlService) Snapshot(w http.ResponseWriter, r *http.Request) {
+	s, ok := cs.session(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
 
-	tmp, err := json.Marshal(output)
-	if err != nil {
+	tmp, err := s.snapshot(0)
+	if err == errNoRun {
+		w.WriteHeader(http.StatusConflict)
+		return
+	} else if err != nil {
 		cs.log.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	path, err := filepath.Abs(fmt.Sprintf("%s/%s", cs.path, "results.json"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(tmp)
+}
+
+// AbortRun stops the current run without evaluating it, e.g. after a
+// workload crashed. The run is left out of summaries and reports.
+func (cs *ControlService) AbortRun(w http.ResponseWriter, r *http.Request) {
+	s, ok := cs.session(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var req abortRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "aborted by request"
+	}
+
+	err = s.abortRun(req.Reason)
+	if err == errNoRun {
+		w.WriteHeader(http.StatusConflict)
+		return
+	} else if err != nil {
+		cs.log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (cs *ControlService) EndBenchmark(w http.ResponseWriter, r *http.Request) {
+	s, ok := cs.session(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	cs.mu.Lock()
+	delete(cs.sessions, s.ID)
+	if cs.latest == s.ID {
+		cs.latest = ""
+	}
+	cs.mu.Unlock()
+
+	err := s.end()
 	if err != nil {
 		cs.log.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+}
 
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0600)
+// StartBenchmark opens a new session and responds with its ID. The body
+// optionally names the benchmark.
+func (cs *ControlService) StartBenchmark(w http.ResponseWriter, r *http.Request) {
+	var req startRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	cs.mu.Lock()
+	s, err := newSession(cs.log, cs.current, req.Name)
 	if err != nil {
+		cs.mu.Unlock()
 		cs.log.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	defer f.Close()
+	cs.sessions[s.ID] = s
+	cs.latest = s.ID
+	cs.mu.Unlock()
+	cs.log.Infof("Started benchmark session %s in %s", s.ID, s.root)
 
-	_, err = f.Write(tmp)
+	cs.writeInfo(w, s)
+}
+
+// ResumeBenchmark continues an open session or the benchmark in an existing
+// folder and responds with the runs written so far, so the orchestrator
+// knows where to resume.
+func (cs *ControlService) ResumeBenchmark(w http.ResponseWriter, r *http.Request) {
+	var req resumeRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || !results.ValidName(req.ID) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if s, ok := cs.sessions[req.ID]; ok {
+		cs.latest = s.ID
+		cs.writeInfo(w, s)
+		return
+	}
+
+	root := filepath.Join(cs.current.cfg.RootFolder, req.ID)
+	if _, err := os.Stat(root); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	for _, s := range cs.sessions {
+		if filepath.Clean(s.root) == filepath.Clean(root) {
+			cs.latest = s.ID
+			cs.writeInfo(w, s)
+			return
+		}
+	}
+
+	s, err := resumeSession(cs.log, cs.current, root)
 	if err != nil {
 		cs.log.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	cs.sessions[s.ID] = s
+	cs.latest = s.ID
+	cs.log.Infof("Resumed benchmark session %s in %s after run %d", s.ID, s.root, s.run)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(tmp)
+	cs.writeInfo(w, s)
 }
 
-func (cs *ControlService) EndBenchmark(w http.ResponseWriter, r *http.Request) {
-	if cs.cfg.GeneratePlots {
-		cmd := exec.Command("python3", cs.cfg.PlottingScript, "--runs", fmt.Sprintf("%d", cs.run), "--path", cs.root)
-		output, err := cmd.CombinedOutput()
+// list returns all open sessions.
+func (cs *ControlService) list() []*Session {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	sessions := make([]*Session, 0, len(cs.sessions))
+	for _, s := range cs.sessions {
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+func (cs *ControlService) ListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions := cs.list()
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		info, err := s.Info()
 		if err != nil {
-			cs.log.Errorf("%v: %v", err, output)
+			cs.log.Error(err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		infos = append(infos, info)
 	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Root < infos[j].Root })
+
+	writeJSON(w, infos)
 }
 
-func (cs *ControlService) StartBenchmark(w http.ResponseWriter, r *http.Request) {
-	cs.root = fmt.Sprintf("%s/%s", cs.cfg.RootFolder, time.Now().Format("20060102_150405"))
-	err := os.MkdirAll(cs.root, 0755)
+func (cs *ControlService) GetSession(w http.ResponseWriter, r *http.Request) {
+	s, ok := cs.session(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	cs.writeInfo(w, s)
+}
+
+func (cs *ControlService) writeInfo(w http.ResponseWriter, s *Session) {
+	info, err := s.Info()
 	if err != nil {
+		cs.log.Error(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	cs.run = 0
+	writeJSON(w, info)
+}
 
-	w.WriteHeader(http.StatusOK)
+// decodeRunRequest accepts the current request format as well as the plain
+// matching sent by older orchestrators.
+func decodeRunRequest(body []byte) (runRequest, error) {
+	var req runRequest
+	err := json.Unmarshal(body, &req)
+	if err == nil && req.Matching != nil {
+		return req, nil
+	}
+
+	req = runRequest{}
+	err = json.Unmarshal(body, &req.Matching)
+	return req, err
 }
@@ -0,0 +1,297 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/results"
+	"go.uber.org/zap"
+)
+
+func newTestConfig(t *testing.T) config.Config {
+	return config.Config{
+		BufferSize: 10,
+		RootFolder: t.TempDir(),
+		Endpoints: []config.EndpointConfig{
+			{
+				Name:    "test",
+				Url:     "/test",
+				Module:  "GENERIC",
+				Fields:  []string{"value"},
+				Metrics: map[string][]string{"value": {"AVG"}},
+			},
+		},
+	}
+}
+
+func newTestServer(t *testing.T, cfg config.Config) *httptest.Server {
+	log := zap.NewNop().Sugar()
+
+	r := mux.NewRouter()
+	cs, err := NewControlService(log, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.RegisterControlRoutes(r)
+	err = RegisterRoutes(r, log, cfg, cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func post(t *testing.T, url string, body interface{}) *http.Response {
+	tmp, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(tmp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func startSession(t *testing.T, srv *httptest.Server) SessionInfo {
+	resp := post(t, srv.URL+"/start-benchmark", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	var info SessionInfo
+	err := json.NewDecoder(resp.Body).Decode(&info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestConcurrentSessions(t *testing.T) {
+	srv := newTestServer(t, newTestConfig(t))
+
+	first := startSession(t, srv)
+	second := startSession(t, srv)
+	if first.ID == second.ID || first.Root == second.Root {
+		t.Fatalf("expected separate sessions, got: %v, %v", first, second)
+	}
+
+	values := map[string]float64{first.ID: 1.0, second.ID: 3.0}
+	for id, v := range values {
+		prefix := srv.URL + "/sessions/" + id
+		if resp := post(t, prefix+"/start-run", map[string]string{"WL": id}); resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+		}
+		if resp := post(t, prefix+"/test", map[string]float64{"value": v}); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected: %v, got: %v", http.StatusCreated, resp.StatusCode)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	for _, info := range []SessionInfo{first, second} {
+		resp := post(t, srv.URL+"/sessions/"+info.ID+"/end-run", nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+		}
+
+		var results struct {
+			Matching map[string]string             `json:"matching"`
+			Results  map[string]map[string]float64 `json:"results"`
+		}
+		err := json.NewDecoder(resp.Body).Decode(&results)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results.Matching["WL"] != info.ID {
+			t.Fatalf("expected: %v, got: %v", info.ID, results.Matching["WL"])
+		}
+		if results.Results["test"]["value-AVG"] != values[info.ID] {
+			t.Fatalf("expected: %v, got: %v", values[info.ID], results.Results["test"]["value-AVG"])
+		}
+		if _, err := os.Stat(filepath.Join(info.Root, "run001", "results.json")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if resp := post(t, srv.URL+"/sessions/"+first.ID+"/end-benchmark", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	if resp := post(t, srv.URL+"/sessions/"+first.ID+"/test", map[string]float64{"value": 1.0}); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected: %v, got: %v", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestResumeSession(t *testing.T) {
+	cfg := newTestConfig(t)
+	srv := newTestServer(t, cfg)
+
+	abandoned := startSession(t, srv)
+	info := startSession(t, srv)
+	if resp := post(t, srv.URL+"/sessions/"+info.ID+"/start-run", map[string]string{"WL": "a"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	if resp := post(t, srv.URL+"/sessions/"+info.ID+"/end-run", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	if resp := post(t, srv.URL+"/sessions/"+info.ID+"/start-run", map[string]string{"WL": "b"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+
+	// Restart the metric service during the second run.
+	restarted := newTestServer(t, cfg)
+	resp, err := http.Get(restarted.URL + "/sessions/" + info.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var resumed SessionInfo
+	err = json.NewDecoder(resp.Body).Decode(&resumed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resumed.Root != info.Root || resumed.Run != 2 || len(resumed.Runs) != 2 {
+		t.Fatalf("expected session in %s after run 2, got: %+v", info.Root, resumed)
+	}
+	if !resumed.Runs[0].Complete || resumed.Runs[1].Complete || !resumed.Runs[1].Incomplete {
+		t.Fatalf("expected second run to be incomplete, got: %+v", resumed.Runs)
+	}
+	if resumed.Runs[1].Matching["WL"] != "b" {
+		t.Fatalf("expected: %v, got: %v", "b", resumed.Runs[1].Matching["WL"])
+	}
+	// Only the latest session is resumed.
+	other, err := http.Get(restarted.URL + "/sessions/" + abandoned.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Body.Close()
+	if other.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected: %v, got: %v", http.StatusNotFound, other.StatusCode)
+	}
+
+	// The orchestrator ends the interrupted run and learns it failed.
+	resp = post(t, restarted.URL+"/sessions/"+info.ID+"/end-run", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	var res results.Results
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != results.StatusFailed || res.Matching["WL"] != "b" {
+		t.Fatalf("expected failed run of %v, got: %+v", "b", res)
+	}
+	if resp := post(t, restarted.URL+"/sessions/"+info.ID+"/end-run", nil); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected: %v, got: %v", http.StatusConflict, resp.StatusCode)
+	}
+
+	if resp := post(t, restarted.URL+"/sessions/"+info.ID+"/start-run", map[string]string{"WL": "b"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	if _, err := os.Stat(filepath.Join(info.Root, "run003")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAbortRun(t *testing.T) {
+	srv := newTestServer(t, newTestConfig(t))
+	info := startSession(t, srv)
+	prefix := srv.URL + "/sessions/" + info.ID
+
+	// Without a run nothing is aborted, measurements are still accepted.
+	if resp := post(t, prefix+"/abort-run", nil); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected: %v, got: %v", http.StatusConflict, resp.StatusCode)
+	}
+	if resp := post(t, prefix+"/test", map[string]float64{"value": 1.0}); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected: %v, got: %v", http.StatusCreated, resp.StatusCode)
+	}
+
+	if resp := post(t, prefix+"/start-run", map[string]string{"WL": "a"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	if resp := post(t, prefix+"/abort-run", map[string]string{"reason": "container crashed"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+
+	if resp := post(t, prefix+"/test", map[string]float64{"value": 1.0}); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected: %v, got: %v", http.StatusConflict, resp.StatusCode)
+	}
+	if resp := post(t, prefix+"/end-run", nil); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected: %v, got: %v", http.StatusConflict, resp.StatusCode)
+	}
+	if _, err := os.Stat(filepath.Join(info.Root, "run001", "results.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected: %v, got: %v", os.ErrNotExist, err)
+	}
+	if _, err := os.Stat(filepath.Join(info.Root, "run001", "aborted.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next run collects measurements again.
+	if resp := post(t, prefix+"/start-run", map[string]string{"WL": "a"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	if resp := post(t, prefix+"/test", map[string]float64{"value": 1.0}); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected: %v, got: %v", http.StatusCreated, resp.StatusCode)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	srv := newTestServer(t, newTestConfig(t))
+	info := startSession(t, srv)
+	prefix := srv.URL + "/sessions/" + info.ID
+
+	if resp := post(t, prefix+"/snapshot", nil); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected: %v, got: %v", http.StatusConflict, resp.StatusCode)
+	}
+	if resp := post(t, prefix+"/start-run", map[string]string{"WL": "a"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+
+	// Snapshots must not disturb the aggregation of the remaining run.
+	for i, v := range []float64{1.0, 3.0, 8.0} {
+		if resp := post(t, prefix+"/test", map[string]float64{"value": v}); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected: %v, got: %v", http.StatusCreated, resp.StatusCode)
+		}
+		time.Sleep(50 * time.Millisecond)
+
+		resp := post(t, prefix+"/snapshot", nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+		}
+		var snapshot results.Snapshot
+		err := json.NewDecoder(resp.Body).Decode(&snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []float64{1.0, 2.0, 4.0}[i]
+		if snapshot.Results["test"]["value-AVG"] != expected {
+			t.Fatalf("expected: %v, got: %v", expected, snapshot.Results["test"]["value-AVG"])
+		}
+	}
+
+	resp := post(t, prefix+"/end-run", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	snapshots, err := results.ReadSnapshots(filepath.Join(info.Root, "run001"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 3 {
+		t.Fatalf("expected: %v, got: %v", 3, len(snapshots))
+	}
+	if resp := post(t, prefix+"/snapshot", nil); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected: %v, got: %v", http.StatusConflict, resp.StatusCode)
+	}
+}
@@ -0,0 +1,240 @@
+package routes
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sbaeurle/comb/metrics/config"
+)
+
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// Dashboard serves a live view of all open sessions. Updates are pushed to
+// the browsers with Server-Sent Events once per interval.
+type Dashboard struct {
+	log      config.Logger
+	sessions *ControlService
+	interval time.Duration
+
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+	stop    chan struct{}
+}
+
+// DashboardUpdate is the content of a single event.
+type DashboardUpdate struct {
+	Time     time.Time         `json:"time"`
+	Sessions []DashboardStatus `json:"sessions"`
+}
+
+// DashboardStatus describes a session and the measurements it received
+// since the last update. Rates are measurements per second per endpoint.
+type DashboardStatus struct {
+	ID       string                            `json:"id"`
+	Name     string                            `json:"name,omitempty"`
+	Root     string                            `json:"root"`
+	Run      int                               `json:"run"`
+	Matching map[string]string                 `json:"matching"`
+	Rates    map[string]float64                `json:"rates"`
+	Fields   map[string]map[string]FieldUpdate `json:"fields"`
+}
+
+// FieldUpdate summarizes the values of a field received since the last update.
+type FieldUpdate struct {
+	Mean  float64 `json:"mean"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"count"`
+}
+
+// NewDashboard creates the dashboard of the sessions of cs. Updates are
+// only collected while clients are connected.
+func NewDashboard(log config.Logger, sessions *ControlService, interval time.Duration) *Dashboard {
+	return &Dashboard{log: log, sessions: sessions, interval: interval, clients: make(map[chan []byte]struct{})}
+}
+
+// RegisterDashboardRoutes adds the dashboard page to page and its event
+// stream to events. The page holds no data, it passes its access_token
+// query parameter on to the event stream.
+func (d *Dashboard) RegisterDashboardRoutes(page *mux.Router, events *mux.Router) {
+	page.HandleFunc("/dashboard", d.Page).Methods("GET")
+	events.HandleFunc("/dashboard/events", d.Events).Methods("GET")
+}
+
+func (d *Dashboard) Page(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardPage)
+}
+
+// Events streams an update per interval until the client disconnects.
+func (d *Dashboard) Events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before answering, the client may count on the updates covering
+	// everything after the response.
+	events := make(chan []byte, 16)
+	d.subscribe(events)
+	defer d.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			_, err := fmt.Fprintf(w, "data: %s\n\n", event)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// subscribe adds a client, the first one starts broadcasting.
+func (d *Dashboard) subscribe(events chan []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clients[events] = struct{}{}
+	if d.stop == nil {
+		// Measurements received without clients are not part of the first update.
+		for _, s := range d.sessions.list() {
+			s.live.reset()
+		}
+		d.stop = make(chan struct{})
+		go d.broadcast(d.stop)
+	}
+}
+
+// unsubscribe removes a client, the last one stops broadcasting.
+func (d *Dashboard) unsubscribe(events chan []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.clients, events)
+	if len(d.clients) == 0 && d.stop != nil {
+		close(d.stop)
+		d.stop = nil
+	}
+}
+
+// broadcast collects the statistics of all sessions once per interval and
+// sends them to every client until stop is closed. Slow clients miss
+// updates instead of blocking the others.
+func (d *Dashboard) broadcast(stop chan struct{}) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		var now time.Time
+		select {
+		case <-stop:
+			return
+		case now = <-ticker.C:
+		}
+
+		update := DashboardUpdate{Time: now, Sessions: []DashboardStatus{}}
+		for _, s := range d.sessions.list() {
+			update.Sessions = append(update.Sessions, s.status(d.interval))
+		}
+		sort.Slice(update.Sessions, func(i, j int) bool { return update.Sessions[i].Root < update.Sessions[j].Root })
+
+		event, err := json.Marshal(update)
+		if err != nil {
+			d.log.Error(err)
+			continue
+		}
+
+		d.mu.Lock()
+		for c := range d.clients {
+			select {
+			case c <- event:
+			default:
+			}
+		}
+		d.mu.Unlock()
+	}
+}
+
+// status describes the session and resets its live statistics.
+func (s *Session) status(interval time.Duration) DashboardStatus {
+	counts, fields := s.live.reset()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := DashboardStatus{
+		ID:       s.ID,
+		Name:     s.name,
+		Root:     s.root,
+		Run:      s.run,
+		Matching: s.mapping,
+		Rates:    make(map[string]float64),
+		Fields:   fields,
+	}
+	for _, endpoint := range s.cfg.Endpoints {
+		st.Rates[endpoint.Name] = float64(counts[endpoint.Name]) / interval.Seconds()
+	}
+	return st
+}
+
+// liveStats counts the measurements of a session between two dashboard updates.
+type liveStats struct {
+	mu     sync.Mutex
+	counts map[string]int
+	fields map[string]map[string]FieldUpdate
+}
+
+func newLiveStats() *liveStats {
+	return &liveStats{counts: make(map[string]int), fields: make(map[string]map[string]FieldUpdate)}
+}
+
+// add counts a measurement of the endpoint and summarizes the fields shown on the dashboard.
+func (l *liveStats) add(endpoint config.EndpointConfig, values map[string]interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts[endpoint.Name]++
+
+	for _, field := range endpoint.Dashboard {
+		v, ok := values[field].(float64)
+		if !ok || math.IsNaN(v) {
+			continue
+		}
+		if l.fields[endpoint.Name] == nil {
+			l.fields[endpoint.Name] = make(map[string]FieldUpdate)
+		}
+
+		f, ok := l.fields[endpoint.Name][field]
+		if !ok {
+			f = FieldUpdate{Min: v, Max: v}
+		}
+		f.Mean = (f.Mean*float64(f.Count) + v) / float64(f.Count+1)
+		f.Min = math.Min(f.Min, v)
+		f.Max = math.Max(f.Max, v)
+		f.Count++
+		l.fields[endpoint.Name][field] = f
+	}
+}
+
+func (l *liveStats) reset() (map[string]int, map[string]map[string]FieldUpdate) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counts, fields := l.counts, l.fields
+	l.counts = make(map[string]int)
+	l.fields = make(map[string]map[string]FieldUpdate)
+	return counts, fields
+}
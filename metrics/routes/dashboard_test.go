@@ -0,0 +1,92 @@
+package routes
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+func TestDashboardEvents(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Endpoints[0].Dashboard = []string{"value"}
+	log := zap.NewNop().Sugar()
+
+	r := mux.NewRouter()
+	cs, err := NewControlService(log, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.RegisterControlRoutes(r)
+	d := NewDashboard(log, cs, 20*time.Millisecond)
+	d.RegisterDashboardRoutes(r, r)
+	err = RegisterRoutes(r, log, cfg, cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	info := startSession(t, srv)
+	post(t, srv.URL+"/start-run", map[string]interface{}{"matching": map[string]string{"Test": "node"}})
+
+	resp, err := http.Get(srv.URL + "/dashboard/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	for _, v := range []float64{2, 4} {
+		post(t, srv.URL+"/test", map[string]float64{"value": v})
+	}
+
+	// Measurements may be spread over several updates.
+	count, min, max := 0, math.Inf(1), math.Inf(-1)
+	scanner := bufio.NewScanner(resp.Body)
+	for count < 2 && scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var update DashboardUpdate
+		err = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &update)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(update.Sessions) != 1 || update.Sessions[0].ID != info.ID || update.Sessions[0].Run != 1 {
+			t.Fatalf("expected: %v, got: %v", info.ID, update.Sessions)
+		}
+		if f, ok := update.Sessions[0].Fields["test"]["value"]; ok {
+			count += f.Count
+			min = math.Min(min, f.Min)
+			max = math.Max(max, f.Max)
+		}
+	}
+
+	if count != 2 || min != 2 || max != 4 {
+		t.Fatalf("expected: %v, got: %v", []float64{2, 2, 4}, []float64{float64(count), min, max})
+	}
+
+	// Broadcasting stops with the last client.
+	resp.Body.Close()
+	deadline := time.Now().Add(time.Second)
+	for {
+		d.mu.Lock()
+		stopped := d.stop == nil
+		d.mu.Unlock()
+		if stopped {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("broadcast did not stop without clients")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
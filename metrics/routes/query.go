@@ -0,0 +1,213 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/results"
+)
+
+// QueryService offers read-only access to the benchmarks below the result root.
+type QueryService struct {
+	log  config.Logger
+	root string
+}
+
+// RunFile describes a raw output file of a run.
+type RunFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+func NewQueryService(log config.Logger, cfg config.Config) *QueryService {
+	return &QueryService{log: log, root: cfg.RootFolder}
+}
+
+// RegisterQueryRoutes adds the query routes to r.
+func (q *QueryService) RegisterQueryRoutes(r *mux.Router) {
+	r.HandleFunc("/benchmarks", q.ListBenchmarks).Methods("GET")
+	r.HandleFunc("/benchmarks/{benchmark}/runs", q.ListRuns).Methods("GET")
+	r.HandleFunc("/benchmarks/{benchmark}/runs/{run}", q.GetResults).Methods("GET")
+	r.HandleFunc("/benchmarks/{benchmark}/runs/{run}/files", q.ListFiles).Methods("GET")
+	r.HandleFunc("/benchmarks/{benchmark}/runs/{run}/snapshots", q.ListSnapshots).Methods("GET")
+	r.HandleFunc("/benchmarks/{benchmark}/runs/{run}/files/{file}", q.GetFile).Methods("GET")
+}
+
+func (q *QueryService) ListBenchmarks(w http.ResponseWriter, r *http.Request) {
+	benchmarks, err := results.ListBenchmarks(q.root)
+	if err != nil {
+		q.log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if benchmarks == nil {
+		benchmarks = []results.Benchmark{}
+	}
+	writeJSON(w, benchmarks)
+}
+
+// ListRuns lists the runs of a benchmark. Query parameters filter the runs by
+// their matching, e.g. ?Detection=Jetson.
+func (q *QueryService) ListRuns(w http.ResponseWriter, r *http.Request) {
+	path, ok := q.path(w, mux.Vars(r)["benchmark"])
+	if !ok {
+		return
+	}
+
+	runs, err := results.ListRuns(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		q.log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	filter := make(map[string]string)
+	for k, v := range r.URL.Query() {
+		filter[k] = v[0]
+	}
+
+	out := []results.Run{}
+	for _, run := range runs {
+		if run.Matches(filter) {
+			out = append(out, run)
+		}
+	}
+	writeJSON(w, out)
+}
+
+// GetResults returns the results file of a run.
+func (q *QueryService) GetResults(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	path, ok := q.runPath(w, vars["benchmark"], vars["run"])
+	if !ok {
+		return
+	}
+	q.serveFile(w, r, filepath.Join(path, results.ResultsFile))
+}
+
+// ListSnapshots returns the mid-run snapshots of a run ordered by time.
+func (q *QueryService) ListSnapshots(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	path, ok := q.runPath(w, vars["benchmark"], vars["run"])
+	if !ok {
+		return
+	}
+
+	snapshots, err := results.ReadSnapshots(path)
+	if err != nil {
+		q.log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if snapshots == nil {
+		snapshots = []results.Snapshot{}
+	}
+	writeJSON(w, snapshots)
+}
+
+func (q *QueryService) ListFiles(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	path, ok := q.runPath(w, vars["benchmark"], vars["run"])
+	if !ok {
+		return
+	}
+
+	entries, err := os.ReadDir(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		q.log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	files := []RunFile{}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, RunFile{Name: e.Name(), Size: info.Size()})
+	}
+	writeJSON(w, files)
+}
+
+// GetFile streams a raw output file of a run.
+func (q *QueryService) GetFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	path, ok := q.runPath(w, vars["benchmark"], vars["run"])
+	if !ok {
+		return
+	}
+	if !results.ValidName(vars["file"]) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	q.serveFile(w, r, filepath.Join(path, vars["file"]))
+}
+
+// path joins the elements to a path below the result root. Invalid
+// elements are rejected with 400 Bad Request.
+func (q *QueryService) path(w http.ResponseWriter, elems ...string) (string, bool) {
+	for _, e := range elems {
+		if !results.ValidName(e) {
+			w.WriteHeader(http.StatusBadRequest)
+			return "", false
+		}
+	}
+	return filepath.Join(append([]string{q.root}, elems...)...), true
+}
+
+// runPath resolves the folder of a run given by its name or index.
+func (q *QueryService) runPath(w http.ResponseWriter, benchmark string, name string) (string, bool) {
+	path, ok := q.path(w, benchmark)
+	if !ok {
+		return "", false
+	}
+
+	run, err := results.FindRun(path, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		w.WriteHeader(http.StatusNotFound)
+		return "", false
+	} else if err != nil {
+		q.log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return "", false
+	}
+	return run.Path, true
+}
+
+func (q *QueryService) serveFile(w http.ResponseWriter, r *http.Request, path string) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if err != nil {
+		q.log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,130 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/results"
+	"github.com/sbaeurle/comb/metrics/validation"
+)
+
+var errNoLoader = errors.New("configuration cannot be reloaded")
+
+// ReloadResponse is the body of a successful /reload-config.
+type ReloadResponse struct {
+	Version string `json:"version"`
+	Changed bool   `json:"changed"`
+}
+
+// SetLoader sets the function reading the configuration on /reload-config.
+func (cs *ControlService) SetLoader(load func() (config.Config, error)) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.load = load
+}
+
+// reloadConfig reads the configuration with the loader and applies it.
+func (cs *ControlService) reloadConfig() (ReloadResponse, error) {
+	cs.mu.RLock()
+	load := cs.load
+	cs.mu.RUnlock()
+	if load == nil {
+		return ReloadResponse{}, errNoLoader
+	}
+
+	cfg, err := load()
+	if err != nil {
+		return ReloadResponse{}, err
+	}
+	return cs.Reload(cfg)
+}
+
+// Reload validates cfg and applies it to every session when its next run
+// starts, running runs keep their configuration. The listener, tokens and
+// result folder only change by restarting the metric service.
+func (cs *ControlService) Reload(cfg config.Config) (ReloadResponse, error) {
+	if problems := validation.Validate(cfg); problems != nil {
+		return ReloadResponse{}, problems
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	current := cs.current.cfg
+	if cfg.Host != current.Host || cfg.Port != current.Port || cfg.TLS != current.TLS ||
+		!reflect.DeepEqual(cfg.Auth, current.Auth) || cfg.RootFolder != current.RootFolder {
+		cs.log.Warn("Host, Port, TLS, Auth and RootFolder are only changed by a restart")
+	}
+	cfg.Host, cfg.Port, cfg.TLS, cfg.Auth, cfg.RootFolder = current.Host, current.Port, current.TLS, current.Auth, current.RootFolder
+
+	version := cfg.Version()
+	if version == cs.current.version {
+		return ReloadResponse{Version: version}, nil
+	}
+	layout, err := results.NewLayout(cfg)
+	if err != nil {
+		return ReloadResponse{}, err
+	}
+
+	cs.current = loadedConfig{cfg: cfg, layout: layout, version: version}
+	cs.addURLs(cfg)
+	for _, s := range cs.sessions {
+		s.reload(cs.current)
+	}
+	cs.log.Infof("Loaded configuration %s, sessions apply it with their next run", version)
+	return ReloadResponse{Version: version, Changed: true}, nil
+}
+
+// ReloadConfig reads the configuration again and applies it with the next
+// run of every session. Invalid configurations are rejected with their problems.
+func (cs *ControlService) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	resp, err := cs.reloadConfig()
+	var problems config.Problems
+	if errors.As(err, &problems) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(problems)
+		return
+	} else if err == errNoLoader {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	} else if err != nil {
+		cs.log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// addURLs routes the endpoints of cfg. URLs of removed endpoints stay routed,
+// sessions which no longer have them respond with 404 Not Found. The caller
+// holds cs.mu.
+func (cs *ControlService) addURLs(cfg config.Config) {
+	for _, e := range cfg.Endpoints {
+		cs.urls[e.Url] = true
+	}
+}
+
+// isEndpoint matches requests to the URL of a benchmark endpoint, optionally
+// below /sessions/{session}.
+func (cs *ControlService) isEndpoint(scoped bool) mux.MatcherFunc {
+	return func(r *http.Request, _ *mux.RouteMatch) bool {
+		path := r.URL.Path
+		if scoped {
+			parts := strings.SplitN(strings.TrimPrefix(path, "/sessions/"), "/", 2)
+			if len(parts) != 2 || !strings.HasPrefix(path, "/sessions/") {
+				return false
+			}
+			path = "/" + parts[1]
+		}
+
+		cs.mu.RLock()
+		defer cs.mu.RUnlock()
+		return cs.urls[path]
+	}
+}
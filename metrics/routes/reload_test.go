@@ -0,0 +1,118 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/results"
+	"go.uber.org/zap"
+)
+
+func TestReloadConfig(t *testing.T) {
+	log := zap.NewNop().Sugar()
+	cfg := newTestConfig(t)
+	next := cfg
+
+	r := mux.NewRouter()
+	cs, err := NewControlService(log, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.SetLoader(func() (config.Config, error) { return next, nil })
+	cs.RegisterControlRoutes(r)
+	err = RegisterRoutes(r, log, cfg, cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	info := startSession(t, srv)
+	prefix := srv.URL + "/sessions/" + info.ID
+	if resp := post(t, prefix+"/start-run", map[string]string{"WL": "a"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+
+	// Invalid configurations are rejected with their problems.
+	next.Endpoints = []config.EndpointConfig{cfg.Endpoints[0]}
+	next.Endpoints[0].Metrics = map[string][]string{"value": {"MEAN"}}
+	resp := post(t, srv.URL+"/reload-config", nil)
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected: %v, got: %v", http.StatusUnprocessableEntity, resp.StatusCode)
+	}
+	var problems config.Problems
+	err = json.NewDecoder(resp.Body).Decode(&problems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || problems[0].Path != "Endpoints[0].Metrics.value" {
+		t.Fatalf("expected problem of Endpoints[0].Metrics.value, got: %v", problems)
+	}
+
+	next.Endpoints[0].Metrics = map[string][]string{"value": {"AVG", "MAX"}}
+	next.Endpoints = append(next.Endpoints, config.EndpointConfig{Name: "other", Url: "/other", Module: "GENERIC", Fields: []string{"value"}})
+	resp = post(t, srv.URL+"/reload-config", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	var reloaded ReloadResponse
+	err = json.NewDecoder(resp.Body).Decode(&reloaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.Changed || reloaded.Version == cfg.Version() {
+		t.Fatalf("expected new configuration version, got: %+v", reloaded)
+	}
+
+	// The running run keeps its configuration.
+	if resp := post(t, prefix+"/other", map[string]float64{"value": 1.0}); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected: %v, got: %v", http.StatusNotFound, resp.StatusCode)
+	}
+	if resp := post(t, prefix+"/test", map[string]float64{"value": 1.0}); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected: %v, got: %v", http.StatusCreated, resp.StatusCode)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if resp := post(t, prefix+"/end-run", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+
+	if resp := post(t, prefix+"/start-run", map[string]string{"WL": "a"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	for _, endpoint := range []string{"/test", "/other"} {
+		if resp := post(t, prefix+endpoint, map[string]float64{"value": 2.0}); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected: %v, got: %v", http.StatusCreated, resp.StatusCode)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	resp = post(t, prefix+"/end-run", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected: %v, got: %v", http.StatusOK, resp.StatusCode)
+	}
+	var out struct {
+		Results map[string]map[string]float64 `json:"results"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Results["test"]["value-MAX"] != 2.0 {
+		t.Fatalf("expected: %v, got: %v", 2.0, out.Results["test"])
+	}
+
+	for run, version := range map[string]string{"run001": cfg.Version(), "run002": reloaded.Version} {
+		m, err := results.ReadManifest(filepath.Join(info.Root, run))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.ConfigVersion != version {
+			t.Fatalf("expected: %v, got: %v", version, m.ConfigVersion)
+		}
+	}
+}
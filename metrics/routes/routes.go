@@ -4,37 +4,71 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sbaeurle/comb/metrics/config"
 	"github.com/sbaeurle/comb/metrics/modules"
+	"github.com/sbaeurle/comb/metrics/results"
 )
 
-func RegisterRoutes(r *mux.Router, log config.Logger, cfg config.Config) (map[string]modules.Module, error) {
-	modz := make(map[string]modules.Module)
+// RegisterRoutes adds the benchmark endpoints to r. Every endpoint is
+// available unscoped and below /sessions/{session}. Endpoints added by
+// reloading the configuration are routed as well.
+func RegisterRoutes(r *mux.Router, log config.Logger, cfg config.Config, cs *ControlService) error {
 	for _, endpoint := range cfg.Endpoints {
-		mod, ok := modules.Modules[endpoint.Module]
+		if _, ok := modules.Modules[endpoint.Module]; !ok {
+			return fmt.Errorf("module %s not found", endpoint.Module)
+		}
+		_, err := timestampUnit(endpoint)
+		if err != nil {
+			return err
+		}
+		switch endpoint.Completeness.Severity {
+		case "", results.StatusWarn, results.StatusFailed:
+		default:
+			return fmt.Errorf("endpoint %s: unknown completeness severity %s", endpoint.Name, endpoint.Completeness.Severity)
+		}
+	}
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		received := time.Now()
+		s, ok := cs.session(r)
 		if !ok {
-			return nil, fmt.Errorf("module %s not found", endpoint.Module)
+			w.WriteHeader(http.StatusNotFound)
+			return
 		}
 
-		comm := make(chan []byte, cfg.BufferSize)
-		modz[endpoint.Name] = mod(log, endpoint, comm)
-		go modz[endpoint.Name].AddMeasurements()
-
-		handler := func(w http.ResponseWriter, r *http.Request) {
-			// Forward HTTP Body to separate GO routine
-			tmp, err := ioutil.ReadAll(r.Body)
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-			comm <- tmp
-			w.WriteHeader(http.StatusCreated)
+		// Forward HTTP Body to separate GO routine
+		tmp, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
 		}
 
-		r.HandleFunc(endpoint.Url, handler).Methods("POST")
+		url := r.URL.Path
+		if id, ok := mux.Vars(r)["session"]; ok {
+			url = strings.TrimPrefix(url, "/sessions/"+id)
+		}
+		err = s.ingest(url, s.sources.Resolve(r), tmp, received, 0)
+		if err == errSessionClosed || err == errNoEndpoint {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		} else if err == errRunAborted || err == errStaleSample {
+			w.WriteHeader(http.StatusConflict)
+			return
+		} else if err != nil {
+			log.Error(err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
 	}
 
-	return modz, nil
+	r.HandleFunc("/ingest-batch", cs.IngestBatch).Methods("POST")
+	r.PathPrefix("/sessions/{session}/").MatcherFunc(cs.isEndpoint(true)).HandlerFunc(handler).Methods("POST")
+	r.MatcherFunc(cs.isEndpoint(false)).HandlerFunc(handler).Methods("POST")
+
+	return nil
 }
@@ -0,0 +1,712 @@
+package routes
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sbaeurle/comb/metrics/clock"
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/expr"
+	"github.com/sbaeurle/comb/metrics/measurement"
+	"github.com/sbaeurle/comb/metrics/modules"
+	"github.com/sbaeurle/comb/metrics/report"
+	"github.com/sbaeurle/comb/metrics/results"
+	"github.com/sbaeurle/comb/metrics/summary"
+	"github.com/sbaeurle/comb/metrics/version"
+)
+
+var (
+	errSessionClosed = errors.New("benchmark session closed")
+	errRunAborted    = errors.New("run aborted")
+	errNoRun         = errors.New("no run started")
+	errNoEndpoint    = errors.New("unknown endpoint")
+	errStaleSample   = errors.New("measurement does not belong to the current run")
+)
+
+// loadedConfig is a version of the configuration with the layout of its
+// result folders.
+type loadedConfig struct {
+	cfg     config.Config
+	layout  *results.Layout
+	version string
+}
+
+// ingestEndpoint is a benchmark endpoint of a session.
+type ingestEndpoint struct {
+	cfg     config.EndpointConfig
+	unit    time.Duration
+	derived *expr.Derivation
+}
+
+// fields decodes the fields of a measurement for the dashboard and the
+// completeness rules, including the derived fields. Bodies which are no JSON
+// object have no fields.
+func (e ingestEndpoint) fields(body []byte) map[string]interface{} {
+	if len(e.cfg.Dashboard) == 0 && len(e.cfg.Completeness.RequiredFields) == 0 {
+		return nil
+	}
+	var values map[string]interface{}
+	if json.Unmarshal(body, &values) != nil || e.derived == nil {
+		return values
+	}
+
+	numbers := make(map[string]float64)
+	for k, v := range values {
+		if f, ok := v.(float64); ok {
+			numbers[k] = f
+		}
+	}
+	e.derived.Apply(numbers)
+	for name := range e.cfg.Derived {
+		if v, ok := numbers[name]; ok {
+			values[name] = v
+		}
+	}
+	return values
+}
+
+// Session is a single benchmark campaign. Every session owns its module
+// instances, so several campaigns can be collected at the same time.
+// Reloaded configurations are applied when the next run starts.
+type Session struct {
+	ID      string
+	log     config.Logger
+	cfg     config.Config
+	layout  *results.Layout
+	version string
+	pending *loadedConfig
+	sources *Sources
+	clocks  *clock.Estimator
+	live    *liveStats
+
+	// lifecycle guards the module channels against measurements arriving while
+	// the session ends. Measurements of aborted runs are rejected until the next run starts.
+	lifecycle sync.RWMutex
+	closed    bool
+	aborted   bool
+	stats     *runStats
+	endpoints map[string]ingestEndpoint
+	modz      map[string]modules.Module
+	chans     map[string]chan measurement.Measurement
+
+	mu       sync.Mutex
+	running  bool
+	stop     chan struct{}
+	name     string
+	started  time.Time
+	ended    *time.Time
+	root     string
+	path     string
+	run      int
+	mapping  map[string]string
+	workers  []measurement.Source
+	manifest results.Manifest
+}
+
+// SessionInfo describes the state of a session.
+type SessionInfo struct {
+	ID   string        `json:"id"`
+	Name string        `json:"name,omitempty"`
+	Root string        `json:"root"`
+	Run  int           `json:"run"`
+	Runs []results.Run `json:"runs"`
+}
+
+func newSession(log config.Logger, c loadedConfig, name string) (*Session, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	root, err := c.layout.CreateBenchmark(c.cfg.RootFolder, results.BenchmarkVars{Name: name, Date: c.layout.Date(started)})
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := openSession(log, c, results.SessionState{ID: id, Name: name, Started: started}, root)
+	if err != nil {
+		return nil, err
+	}
+	return s, s.persist()
+}
+
+// resumeSession continues the benchmark in the folder root. Runs which were
+// started but never ended are marked incomplete, run numbering continues
+// after the last run of the folder. The interrupted run stays current, so
+// the orchestrator can still end or abort it.
+func resumeSession(log config.Logger, c loadedConfig, root string) (*Session, error) {
+	st, err := results.ReadSession(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Benchmarks written before sessions were persisted get a new ID.
+		st.ID, err = newSessionID()
+		st.Started = time.Now()
+	}
+	if err != nil {
+		return nil, err
+	}
+	st.Ended = nil
+
+	runs, err := results.ListRuns(root)
+	if err != nil {
+		return nil, err
+	}
+	var current *results.Run
+	for i, run := range runs {
+		if st.Path != "" && !run.Complete && !run.Aborted && filepath.Clean(run.Path) == filepath.Join(root, filepath.FromSlash(st.Path)) {
+			current = &runs[i]
+		}
+		if !run.Complete && !run.Incomplete && !run.Aborted {
+			log.Warnf("Marking interrupted run %s incomplete", run.Path)
+			err = results.MarkIncomplete(run.Path, "metric service stopped during the run")
+			if err != nil {
+				return nil, err
+			}
+		}
+		if run.Index > st.Run {
+			st.Run = run.Index
+		}
+	}
+
+	s, err := openSession(log, c, st, root)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		s.path, err = filepath.Abs(current.Path)
+		if err != nil {
+			return nil, err
+		}
+		s.mapping = current.Matching
+		s.manifest, err = results.ReadManifest(current.Path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return s, s.persist()
+}
+
+// openSession creates the module instances of a session writing to root.
+func openSession(log config.Logger, c loadedConfig, st results.SessionState, root string) (*Session, error) {
+	s := &Session{
+		ID:      st.ID,
+		log:     log,
+		cfg:     c.cfg,
+		layout:  c.layout,
+		version: c.version,
+		sources: NewSources(),
+		clocks:  clock.NewEstimator(),
+		live:    newLiveStats(),
+		name:    st.Name,
+		started: st.Started,
+		root:    root,
+		run:     st.Run,
+	}
+
+	var err error
+	s.endpoints, s.modz, s.chans, err = setupModules(log, c.cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.startModules()
+	return s, nil
+}
+
+// setupModules creates a module instance for every endpoint of cfg. Endpoints
+// are keyed by their URL, modules and their channels by the endpoint name.
+func setupModules(log config.Logger, cfg config.Config) (map[string]ingestEndpoint, map[string]modules.Module, map[string]chan measurement.Measurement, error) {
+	endpoints := make(map[string]ingestEndpoint)
+	modz := make(map[string]modules.Module)
+	chans := make(map[string]chan measurement.Measurement)
+	for _, endpoint := range cfg.Endpoints {
+		mod, ok := modules.Modules[endpoint.Module]
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("module %s not found", endpoint.Module)
+		}
+		unit, err := timestampUnit(endpoint)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		comm := make(chan measurement.Measurement, cfg.BufferSize)
+		// Invalid derived fields are rejected by the validation.
+		derived, _ := expr.Compile(endpoint.Derived)
+		endpoints[endpoint.Url] = ingestEndpoint{cfg: endpoint, unit: unit, derived: derived}
+		chans[endpoint.Name] = comm
+		modz[endpoint.Name] = mod(log, endpoint, comm)
+	}
+	return endpoints, modz, chans, nil
+}
+
+func (s *Session) startModules() {
+	for _, m := range s.modz {
+		go m.AddMeasurements()
+	}
+}
+
+// reload queues c to be applied when the next run starts.
+func (s *Session) reload(c loadedConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = &c
+}
+
+// apply replaces the module instances by those of the pending configuration.
+// Measurements still buffered are written by the previous instances. The
+// caller holds s.mu.
+func (s *Session) apply() error {
+	c := s.pending
+	s.pending = nil
+
+	endpoints, modz, chans, err := setupModules(s.log, c.cfg)
+	if err != nil {
+		return err
+	}
+
+	s.lifecycle.Lock()
+	if s.closed {
+		s.lifecycle.Unlock()
+		return errSessionClosed
+	}
+	for _, ch := range s.chans {
+		close(ch)
+	}
+	s.endpoints, s.modz, s.chans = endpoints, modz, chans
+	s.lifecycle.Unlock()
+
+	s.cfg, s.layout, s.version = c.cfg, c.layout, c.version
+	s.startModules()
+	s.log.Infof("Session %s uses configuration %s from run %d", s.ID, s.version, s.run+1)
+	return nil
+}
+
+// persist writes the session state to the benchmark folder.
+func (s *Session) persist() error {
+	st := results.SessionState{ID: s.ID, Name: s.name, Started: s.started, Run: s.run, Ended: s.ended}
+	if s.path != "" {
+		root, err := filepath.Abs(s.root)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, s.path)
+		if err != nil {
+			return err
+		}
+		st.Path = filepath.ToSlash(rel)
+	}
+	return results.WriteSession(s.root, st)
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Info describes the session including all runs written so far.
+func (s *Session) Info() (SessionInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs, err := results.ListRuns(s.root)
+	if err != nil {
+		return SessionInfo{}, err
+	}
+	if runs == nil {
+		runs = []results.Run{}
+	}
+	return SessionInfo{ID: s.ID, Name: s.name, Root: s.root, Run: s.run, Runs: runs}, nil
+}
+
+// ingest forwards a measurement received on the endpoint with the given URL
+// to its module. Run is the run a relay tagged the measurement with, 0 if
+// untagged. Measurements of other runs are rejected, see runStats.accepts.
+func (s *Session) ingest(url string, src measurement.Source, body []byte, received time.Time, run int) error {
+	s.lifecycle.RLock()
+	defer s.lifecycle.RUnlock()
+	if s.closed {
+		return errSessionClosed
+	}
+	endpoint, ok := s.endpoints[url]
+	if !ok {
+		return errNoEndpoint
+	}
+	if s.aborted {
+		return errRunAborted
+	}
+	if s.stats != nil && !s.stats.accepts(run, received) {
+		return errStaleSample
+	}
+
+	if len(endpoint.cfg.Timestamps) > 0 {
+		if est, ok := s.clocks.Estimate(src.Node); ok {
+			var err error
+			body, err = correctTimestamps(body, endpoint.cfg.Timestamps, endpoint.unit, est.At(received))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	s.chans[endpoint.cfg.Name] <- measurement.Measurement{Source: src, Received: received, Body: body}
+	values := endpoint.fields(body)
+	s.live.add(endpoint.cfg, values)
+	if s.stats != nil {
+		s.stats.add(endpoint.cfg, values, received)
+	}
+	return nil
+}
+
+func (s *Session) startRun(req runRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopRun()
+	if s.pending != nil {
+		err := s.apply()
+		if err != nil {
+			return err
+		}
+	}
+	s.mapping = req.Matching
+	s.workers = make([]measurement.Source, 0, len(req.Sources))
+	for _, src := range req.Sources {
+		s.workers = append(s.workers, src)
+	}
+	sort.Slice(s.workers, func(i, j int) bool { return s.workers[i].Workload < s.workers[j].Workload })
+	s.sources.Register(req.Sources)
+	s.clocks.Reset()
+	s.run++
+
+	s.lifecycle.Lock()
+	s.aborted = false
+	s.stats = newRunStats(s.run, time.Now())
+	s.lifecycle.Unlock()
+	err := s.persist()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	rel, err := s.layout.CreateRun(s.root, results.RunVars{
+		Name:      s.name,
+		Date:      s.layout.Date(start),
+		Run:       s.run,
+		Matching:  s.mapping,
+		Workloads: results.Deployments(req.Manifest),
+	})
+	if err != nil {
+		return err
+	}
+	err = s.index(rel, start)
+	if err != nil {
+		return err
+	}
+
+	s.path, err = filepath.Abs(filepath.Join(s.root, rel))
+	if err != nil {
+		return err
+	}
+	err = s.persist()
+	if err != nil {
+		return err
+	}
+
+	s.manifest = results.Manifest{
+		Version:       version.String(),
+		Session:       s.ID,
+		Run:           s.run,
+		Start:         start,
+		Matching:      s.mapping,
+		Config:        s.cfg.Redacted(),
+		ConfigVersion: s.version,
+		Orchestration: req.Manifest,
+	}
+	err = results.WriteManifest(s.path, s.manifest)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range s.modz {
+		err = m.StartMeasurement(s.path)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.running = true
+	if s.cfg.SnapshotInterval > 0 {
+		s.stop = make(chan struct{})
+		go s.takeSnapshots(s.run, s.cfg.SnapshotInterval, s.stop)
+	}
+	return nil
+}
+
+// stopRun ends periodic snapshots of the current run. The caller holds s.mu.
+func (s *Session) stopRun() {
+	s.running = false
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+func (s *Session) takeSnapshots(run int, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_, err := s.snapshot(run)
+			if err != nil && err != errNoRun {
+				s.log.Error(err)
+			}
+		}
+	}
+}
+
+// snapshot aggregates the metrics of the running run without ending it and
+// writes them to the snapshot folder of the run. Run 0 selects the current
+// run, otherwise the snapshot is only taken while the given run is running.
+// Modules are evaluated without holding s.mu, so the session keeps taking
+// measurements and control requests meanwhile.
+func (s *Session) snapshot(run int) ([]byte, error) {
+	s.mu.Lock()
+	if !s.running || (run != 0 && run != s.run) {
+		s.mu.Unlock()
+		return nil, errNoRun
+	}
+
+	now := time.Now()
+	s.lifecycle.RLock()
+	_, endpoints := s.stats.check(s.cfg.Endpoints, now)
+	s.lifecycle.RUnlock()
+
+	modz, path := s.modz, s.path
+	snapshot := results.Snapshot{
+		Time:      now,
+		Elapsed:   now.Sub(s.manifest.Start).Seconds(),
+		Run:       s.run,
+		Matching:  s.mapping,
+		Endpoints: endpoints,
+	}
+	s.mu.Unlock()
+
+	var err error
+	snapshot.Results, err = collect(modz, endpoints, true)
+	if err != nil {
+		return nil, err
+	}
+	return results.WriteSnapshot(path, snapshot)
+}
+
+// collect aggregates the metrics of every module which received measurements.
+// Snapshots leave out modules which cannot be aggregated before the run ended.
+func collect(modz map[string]modules.Module, endpoints map[string]results.EndpointStatus, snapshot bool) (map[string]map[string]float64, error) {
+	collected := make(map[string]map[string]float64)
+	for k, m := range modz {
+		// Modules without measurements have nothing to evaluate.
+		if endpoints[k].Samples == 0 {
+			continue
+		}
+		if sn, ok := m.(modules.Snapshotter); snapshot && ok && !sn.Snapshots() {
+			continue
+		}
+		tmp, err := m.CollectMetrics()
+		if err != nil {
+			return nil, err
+		}
+
+		collected[k] = tmp
+	}
+
+	// Joined metrics depend on the metrics of other endpoints.
+	samples := make(map[string]int)
+	for k, e := range endpoints {
+		samples[k] = e.Samples
+	}
+	for k, m := range modz {
+		j, ok := m.(modules.Joiner)
+		if !ok || collected[k] == nil {
+			continue
+		}
+		for name, v := range j.Join(collected, samples) {
+			collected[k][name] = v
+		}
+	}
+	return collected, nil
+}
+
+// index adds the current run to the index of the benchmark folder. Benchmarks
+// written without an index get one listing their previous runs first.
+func (s *Session) index(rel string, start time.Time) error {
+	idx, err := results.ReadIndex(s.root)
+	if errors.Is(err, fs.ErrNotExist) {
+		runs, err := results.ListRuns(s.root)
+		if err != nil {
+			return err
+		}
+		for _, run := range runs {
+			// The folder of the current run may already be found by the scan.
+			if run.Index != s.run {
+				idx.Runs = append(idx.Runs, results.IndexEntry{Run: run.Index, Path: run.Name, Matching: run.Matching})
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+
+	idx.Name = s.name
+	idx.Session = s.ID
+	idx.Runs = append(idx.Runs, results.IndexEntry{Run: s.run, Path: filepath.ToSlash(rel), Started: start, Matching: s.mapping})
+	return results.WriteIndex(s.root, idx)
+}
+
+// endRun collects the metrics of all modules and writes them to results.json.
+func (s *Session) endRun() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		return nil, errNoRun
+	}
+	s.stopRun()
+
+	// The run was interrupted by a restart and already marked incomplete,
+	// its measurements are lost.
+	if s.stats == nil {
+		path := s.path
+		s.path = ""
+		return results.Write(path, results.Results{Matching: s.mapping, Status: results.StatusFailed})
+	}
+
+	end := time.Now()
+	s.lifecycle.RLock()
+	status, endpoints := s.stats.check(s.cfg.Endpoints, end)
+	s.lifecycle.RUnlock()
+	if status != results.StatusOK {
+		s.log.Warnf("Run %s is %s: %v", s.path, status, endpoints)
+	}
+
+	collected, err := collect(s.modz, endpoints, false)
+	if err != nil {
+		return nil, err
+	}
+
+	s.manifest.End = &end
+	err = results.WriteManifest(s.path, s.manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return results.Write(s.path, results.Results{
+		Matching:  s.mapping,
+		Sources:   s.workers,
+		Clocks:    s.clocks.Reports(),
+		Status:    status,
+		Endpoints: endpoints,
+		Results:   collected,
+		Units:     s.units(collected),
+	})
+}
+
+// units returns the units of the collected metrics per endpoint.
+func (s *Session) units(collected map[string]map[string]float64) map[string]map[string]string {
+	out := make(map[string]map[string]string)
+	for _, e := range s.cfg.Endpoints {
+		if units := modules.Units(e, collected[e.Name]); len(units) > 0 {
+			out[e.Name] = units
+		}
+	}
+	return out
+}
+
+// abortRun stops collecting measurements and marks the current run aborted
+// without evaluating it.
+func (s *Session) abortRun(reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" {
+		return errNoRun
+	}
+	s.lifecycle.Lock()
+	s.aborted = true
+	s.lifecycle.Unlock()
+	s.stopRun()
+
+	end := time.Now()
+	s.manifest.End = &end
+	err := results.WriteManifest(s.path, s.manifest)
+	if err != nil {
+		return err
+	}
+
+	err = results.MarkAborted(s.path, reason)
+	if err != nil {
+		return err
+	}
+	s.log.Warnf("Aborted run %s: %s", s.path, reason)
+	s.path = ""
+	return nil
+}
+
+// end finishes the benchmark and stops all modules of the session.
+func (s *Session) end() error {
+	s.lifecycle.Lock()
+	if !s.closed {
+		s.closed = true
+		for _, c := range s.chans {
+			close(c)
+		}
+	}
+	s.lifecycle.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopRun()
+	ended := time.Now()
+	s.ended = &ended
+	err := s.persist()
+	if err != nil {
+		return err
+	}
+
+	sum, err := summary.Load(s.root)
+	if err != nil {
+		return err
+	}
+	if s.cfg.Summary.SortBy != "" {
+		err = sum.Sort(s.cfg.Summary.SortBy, s.cfg.Summary.Descending)
+		if err != nil {
+			s.log.Warnf("Summary of %s not sorted: %v", s.root, err)
+		}
+	}
+	err = summary.Write(s.root, sum)
+	if err != nil {
+		return err
+	}
+
+	if s.cfg.GeneratePlots {
+		path, err := report.Generate(s.root, s.cfg)
+		if err != nil {
+			return err
+		}
+		s.log.Infof("Wrote benchmark report %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/sbaeurle/comb/metrics/measurement"
+)
+
+// Headers used by workloads to describe themselves.
+const (
+	HeaderSourceToken = "X-Comb-Source-Token"
+	HeaderNode        = "X-Comb-Node"
+	HeaderWorkload    = "X-Comb-Workload"
+	HeaderContainer   = "X-Comb-Container"
+)
+
+// Sources resolves the origin of incoming measurements. A token issued by the
+// orchestrator for the current run takes precedence over the X-Comb headers,
+// the remote address is used if the node is not known otherwise.
+type Sources struct {
+	mu     sync.RWMutex
+	tokens map[string]measurement.Source
+}
+
+func NewSources() *Sources {
+	return &Sources{tokens: make(map[string]measurement.Source)}
+}
+
+// Register replaces the known workload tokens.
+func (s *Sources) Register(tokens map[string]measurement.Source) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = make(map[string]measurement.Source, len(tokens))
+	for k, v := range tokens {
+		s.tokens[k] = v
+	}
+}
+
+func (s *Sources) Resolve(r *http.Request) measurement.Source {
+	return s.ResolveHeader(r.Header, r.RemoteAddr)
+}
+
+// ResolveHeader resolves the source of a measurement sent from addr with the
+// given headers, e.g. of a measurement forwarded by a relay.
+func (s *Sources) ResolveHeader(h http.Header, addr string) measurement.Source {
+	src := measurement.Source{
+		Node:      h.Get(HeaderNode),
+		Workload:  h.Get(HeaderWorkload),
+		Container: h.Get(HeaderContainer),
+		Address:   addr,
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		src.Address = host
+	}
+
+	if token := h.Get(HeaderSourceToken); token != "" {
+		s.mu.RLock()
+		registered, ok := s.tokens[token]
+		s.mu.RUnlock()
+		if ok {
+			registered.Address = src.Address
+			src = registered
+		}
+	}
+
+	if src.Node == "" {
+		src.Node = src.Address
+	}
+	return src
+}
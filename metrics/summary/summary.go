@@ -0,0 +1,179 @@
+// Package summary combines the results of all runs of a benchmark into a
+// single table with one row per run.
+package summary
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/sbaeurle/comb/metrics/results"
+)
+
+// Files written to the benchmark folder.
+const (
+	CSVFile  = "summary.csv"
+	JSONFile = "summary.json"
+)
+
+// Summary is the leaderboard of a benchmark. Metrics are named
+// "<endpoint>/<metric>", e.g. "tracking/processing-time-AVG".
+type Summary struct {
+	Workloads []string `json:"workloads"`
+	Metrics   []string `json:"metrics"`
+	Rows      []Row    `json:"rows"`
+}
+
+// Row holds the assignment and the collected metrics of a complete run.
+type Row struct {
+	Run      string                        `json:"run"`
+	Index    int                           `json:"index"`
+	Matching map[string]string             `json:"matching"`
+	Nodes    map[string]results.Deployment `json:"nodes,omitempty"`
+	Values   map[string]float64            `json:"values"`
+}
+
+// Load builds the summary of all complete runs of the benchmark folder path.
+func Load(path string) (Summary, error) {
+	var s Summary
+	runs, err := results.ListRuns(path)
+	if err != nil {
+		return s, err
+	}
+
+	workloads := make(map[string]bool)
+	metrics := make(map[string]bool)
+	for _, run := range runs {
+		if !run.Valid() {
+			continue
+		}
+		r, err := results.Read(run.Path)
+		if err != nil {
+			return s, err
+		}
+
+		row := Row{Run: run.Name, Index: run.Index, Matching: r.Matching, Values: make(map[string]float64)}
+		if m, err := results.ReadManifest(run.Path); err == nil {
+			row.Nodes = results.Deployments(m.Orchestration)
+		}
+		for workload := range r.Matching {
+			workloads[workload] = true
+		}
+		for endpoint, values := range r.Results {
+			for metric, v := range values {
+				key := fmt.Sprintf("%s/%s", endpoint, metric)
+				row.Values[key] = v
+				metrics[key] = true
+			}
+		}
+		s.Rows = append(s.Rows, row)
+	}
+
+	s.Workloads = keys(workloads)
+	s.Metrics = keys(metrics)
+	return s, nil
+}
+
+// Sort orders the rows by metric, runs without the metric come last.
+func (s *Summary) Sort(metric string, descending bool) error {
+	known := false
+	for _, m := range s.Metrics {
+		known = known || m == metric
+	}
+	if !known {
+		return fmt.Errorf("unknown metric %s", metric)
+	}
+
+	sort.SliceStable(s.Rows, func(i, j int) bool {
+		a, okA := s.Rows[i].Values[metric]
+		b, okB := s.Rows[j].Values[metric]
+		if !okA || !okB {
+			return okA
+		}
+		if descending {
+			return a > b
+		}
+		return a < b
+	})
+	return nil
+}
+
+// WriteCSV writes one line per run. Every workload has a column with its
+// assignment as well as the node and tag it ran on.
+func (s Summary) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+
+	header := []string{"run"}
+	for _, workload := range s.Workloads {
+		header = append(header, workload, workload+".node", workload+".tag")
+	}
+	header = append(header, s.Metrics...)
+	err := out.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range s.Rows {
+		line := []string{row.Run}
+		for _, workload := range s.Workloads {
+			d := row.Nodes[workload]
+			line = append(line, row.Matching[workload], d.Node, d.Tag)
+		}
+		for _, metric := range s.Metrics {
+			v, ok := row.Values[metric]
+			if ok {
+				line = append(line, strconv.FormatFloat(v, 'g', -1, 64))
+			} else {
+				line = append(line, "")
+			}
+		}
+		err = out.Write(line)
+		if err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// Write stores the summary as CSV and JSON in the benchmark folder dir.
+func Write(dir string, s Summary) error {
+	f, err := os.Create(filepath.Join(dir, CSVFile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = s.WriteCSV(f)
+	if err != nil {
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	if s.Rows == nil {
+		s.Rows = []Row{}
+	}
+	tmp, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, JSONFile), tmp, 0644)
+}
+
+func keys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,102 @@
+package summary
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sbaeurle/comb/metrics/results"
+)
+
+func writeRuns(t *testing.T, root string, runs map[string]float64) {
+	for name, v := range runs {
+		path := filepath.Join(root, name)
+		err := os.Mkdir(path, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = results.Write(path, results.Results{
+			Matching: map[string]string{"Tracking": "APU-" + name},
+			Results:  map[string]map[string]float64{"tracking": {"processing-time-AVG": v}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	type testCase struct {
+		metric     string
+		descending bool
+		out        []string
+		err        bool
+	}
+	tests := map[string]testCase{
+		"ascending":  {metric: "tracking/processing-time-AVG", out: []string{"run002", "run003", "run001"}},
+		"descending": {metric: "tracking/processing-time-AVG", descending: true, out: []string{"run001", "run003", "run002"}},
+		"unknown":    {metric: "tracking/processing-time-MAX", err: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			writeRuns(t, root, map[string]float64{"run001": 30, "run002": 10, "run003": 20})
+			s, err := Load(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = s.Sort(tc.metric, tc.descending)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected: %v, got: %v", tc.err, err)
+			}
+			if tc.err {
+				return
+			}
+			for i, row := range s.Rows {
+				if row.Run != tc.out[i] {
+					t.Fatalf("expected: %v, got: %v", tc.out, s.Rows)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	root := t.TempDir()
+	writeRuns(t, root, map[string]float64{"run001": 12.5})
+	s, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = s.WriteCSV(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "run,Tracking,Tracking.node,Tracking.tag,tracking/processing-time-AVG\nrun001,APU-run001,,,12.5\n"
+	if out.String() != expected {
+		t.Fatalf("expected: %v, got: %v", expected, out.String())
+	}
+}
+
+func TestLoadSkipsAborted(t *testing.T) {
+	root := t.TempDir()
+	writeRuns(t, root, map[string]float64{"run001": 10, "run002": 20})
+	err := results.MarkAborted(filepath.Join(root, "run002"), "container crashed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Rows) != 1 || s.Rows[0].Run != "run001" {
+		t.Fatalf("expected: %v, got: %v", "run001", s.Rows)
+	}
+}
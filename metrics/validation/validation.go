@@ -0,0 +1,365 @@
+// Package validation checks a metric service configuration before any
+// measurement arrives.
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/sbaeurle/comb/metrics/config"
+	"github.com/sbaeurle/comb/metrics/expr"
+	"github.com/sbaeurle/comb/metrics/measurement"
+	"github.com/sbaeurle/comb/metrics/modules"
+	"github.com/sbaeurle/comb/metrics/outputs"
+	"github.com/sbaeurle/comb/metrics/results"
+)
+
+// Validate returns every problem of cfg, nil for a valid configuration.
+func Validate(cfg config.Config) config.Problems {
+	v := &validator{}
+
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		v.add("Port", "%d is not a valid port", cfg.Port)
+	}
+	if cfg.BufferSize < 0 {
+		v.add("BufferSize", "must not be negative")
+	}
+	if cfg.SnapshotInterval < 0 {
+		v.add("SnapshotInterval", "must not be negative")
+	}
+	if cfg.Relay != (config.RelayConfig{}) {
+		v.relay(cfg.Relay)
+	}
+	v.layout(cfg.Layout)
+	v.tls(cfg.TLS)
+
+	names := make(map[string]bool)
+	urls := make(map[string]bool)
+	for i, e := range cfg.Endpoints {
+		path := fmt.Sprintf("Endpoints[%d]", i)
+		if e.Name == "" {
+			v.add(path+".Name", "missing")
+		} else if names[e.Name] {
+			v.add(path+".Name", "duplicate endpoint %s", e.Name)
+		}
+		names[e.Name] = true
+
+		if !strings.HasPrefix(e.Url, "/") {
+			v.add(path+".Url", "must start with /")
+		} else if urls[e.Url] {
+			v.add(path+".Url", "duplicate route %s", e.Url)
+		}
+		urls[e.Url] = true
+
+		v.endpoint(path, e)
+	}
+
+	// The energy per frame joins the frames counted by another endpoint.
+	for i, e := range cfg.Endpoints {
+		path := fmt.Sprintf("Endpoints[%d]", i)
+		if e.Module == "ENERGY" {
+			v.endpointRef(path+".Config.Frames", e.Config["Frames"], names)
+		}
+		for j, s := range e.Stages {
+			if s.Module == "ENERGY" {
+				v.endpointRef(fmt.Sprintf("%s.Stages[%d].Config.Frames", path, j), s.Config["Frames"], names)
+			}
+		}
+	}
+
+	if cfg.Summary.SortBy != "" {
+		v.metric("Summary.SortBy", cfg.Summary.SortBy, names)
+	}
+	if cfg.Compare.Alpha < 0 || cfg.Compare.Alpha >= 1 {
+		v.add("Compare.Alpha", "must be between 0 and 1")
+	}
+	for i, r := range cfg.Compare.Regressions {
+		path := fmt.Sprintf("Compare.Regressions[%d]", i)
+		v.metric(path+".Metric", r.Metric, names)
+		if r.Threshold < 0 {
+			v.add(path+".Threshold", "must not be negative")
+		}
+	}
+
+	return v.problems
+}
+
+// ValidateRelay returns the problems of cfg relevant to the relay command:
+// the listener, the relay settings and the routes of the endpoints.
+func ValidateRelay(cfg config.Config) config.Problems {
+	v := &validator{}
+
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		v.add("Port", "%d is not a valid port", cfg.Port)
+	}
+	v.tls(cfg.TLS)
+	if cfg.Relay.Upstream == "" {
+		v.add("Relay.Upstream", "missing")
+	}
+	v.relay(cfg.Relay)
+
+	urls := make(map[string]bool)
+	for i, e := range cfg.Endpoints {
+		path := fmt.Sprintf("Endpoints[%d].Url", i)
+		if !strings.HasPrefix(e.Url, "/") {
+			v.add(path, "must start with /")
+		} else if urls[e.Url] {
+			v.add(path, "duplicate route %s", e.Url)
+		}
+		urls[e.Url] = true
+	}
+	return v.problems
+}
+
+type validator struct {
+	problems config.Problems
+}
+
+func (v *validator) add(path string, format string, args ...interface{}) {
+	v.problems = append(v.problems, config.Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) layout(cfg config.LayoutConfig) {
+	templates := []struct{ path, text string }{
+		{"Layout.Benchmark", cfg.Benchmark},
+		{"Layout.Run", cfg.Run},
+	}
+	for _, t := range templates {
+		_, err := template.New(t.path).Parse(t.text)
+		if err != nil {
+			v.add(t.path, "%v", err)
+		}
+	}
+}
+
+func (v *validator) tls(cfg config.TLSConfig) {
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		v.add("TLS", "requires both CertFile and KeyFile")
+	}
+	if cfg.ClientCAFile != "" && cfg.CertFile == "" {
+		v.add("TLS.ClientCAFile", "client certificate verification requires CertFile and KeyFile")
+	}
+	files := []struct{ path, name string }{
+		{"TLS.CertFile", cfg.CertFile},
+		{"TLS.KeyFile", cfg.KeyFile},
+		{"TLS.ClientCAFile", cfg.ClientCAFile},
+	}
+	for _, f := range files {
+		if f.name == "" {
+			continue
+		}
+		if _, err := os.Stat(f.name); err != nil {
+			v.add(f.path, "%v", err)
+		}
+	}
+}
+
+func (v *validator) relay(cfg config.RelayConfig) {
+	if cfg.Upstream != "" {
+		u, err := url.Parse(cfg.Upstream)
+		if err != nil {
+			v.add("Relay.Upstream", "%v", err)
+		} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.add("Relay.Upstream", "expected http(s)://<host>[:<port>], got %s", cfg.Upstream)
+		}
+	}
+	if cfg.BatchSize < 0 {
+		v.add("Relay.BatchSize", "must not be negative")
+	}
+	if cfg.Interval < 0 {
+		v.add("Relay.Interval", "must not be negative")
+	}
+	if cfg.DrainTimeout < 0 {
+		v.add("Relay.DrainTimeout", "must not be negative")
+	}
+	if cfg.CAFile != "" {
+		if _, err := os.Stat(cfg.CAFile); err != nil {
+			v.add("Relay.CAFile", "%v", err)
+		}
+	}
+}
+
+func (v *validator) endpoint(path string, e config.EndpointConfig) {
+	fields := make(map[string]bool)
+	for j, f := range e.Fields {
+		if fields[f] {
+			v.add(fmt.Sprintf("%s.Fields[%d]", path, j), "duplicate field %s", f)
+		}
+		fields[f] = true
+		if strings.HasPrefix(f, measurement.FieldPrefix) && !measurement.ValidLabel(strings.TrimPrefix(f, measurement.FieldPrefix)) {
+			v.add(fmt.Sprintf("%s.Fields[%d]", path, j), "unknown source label %s", f)
+		}
+	}
+
+	if _, ok := modules.Modules[e.Module]; !ok {
+		v.add(path+".Module", "unknown module %s", e.Module)
+	} else if validate, ok := modules.Validators[e.Module]; ok {
+		for _, p := range validate(e) {
+			v.problems = append(v.problems, config.Problem{Path: path + "." + p.Path, Message: p.Message, Warning: p.Warning})
+		}
+	}
+
+	v.processing(path, config.StageConfig{Outputs: e.Outputs, Metrics: e.Metrics, GroupBy: e.GroupBy, Derived: e.Derived, FieldTypes: e.FieldTypes})
+	for j, s := range e.Stages {
+		v.processing(fmt.Sprintf("%s.Stages[%d]", path, j), s)
+	}
+	// Derived fields are computed from the corrected timestamps and are
+	// available to the dashboard and the completeness rules.
+	for f := range e.Derived {
+		fields[f] = true
+	}
+	v.fields(path+".Timestamps", e.Timestamps, fields)
+	if _, ok := measurement.TimestampUnits[e.TimestampUnit]; !ok {
+		v.add(path+".TimestampUnit", "unknown timestamp unit %s", e.TimestampUnit)
+	}
+	v.fields(path+".Dashboard", e.Dashboard, fields)
+
+	c := e.Completeness
+	if c.MinSamples < 0 {
+		v.add(path+".Completeness.MinSamples", "must not be negative")
+	}
+	if c.MaxGap < 0 {
+		v.add(path+".Completeness.MaxGap", "must not be negative")
+	}
+	v.fields(path+".Completeness.RequiredFields", c.RequiredFields, fields)
+	switch c.Severity {
+	case "", results.StatusWarn, results.StatusFailed:
+	default:
+		v.add(path+".Completeness.Severity", "unknown severity %s, expected %s or %s", c.Severity, results.StatusWarn, results.StatusFailed)
+	}
+}
+
+// processing checks the settings endpoints and stages of chains share.
+func (v *validator) processing(path string, s config.StageConfig) {
+	for j, o := range s.Outputs {
+		if _, ok := outputs.Outputz[filepath.Ext(o)]; !ok {
+			v.add(fmt.Sprintf("%s.Outputs[%d]", path, j), "unknown output format of %s", o)
+		}
+	}
+
+	fields := make([]string, 0, len(s.FieldTypes))
+	for f := range s.FieldTypes {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	for _, f := range fields {
+		if kind := s.FieldTypes[f].Kind; kind != "" && !isOneOf(kind, modules.Kinds) {
+			v.add(path+".FieldTypes."+f+".Kind", "unknown kind %s, expected one of %s", kind, strings.Join(modules.Kinds, ", "))
+		}
+	}
+
+	for _, m := range config.SortedMetrics(s.Metrics) {
+		v.aggregations(path+".Metrics."+m, s.FieldTypes[m].Kind, s.Metrics[m])
+	}
+
+	v.derived(path+".Derived", s.Derived)
+
+	for j, l := range s.GroupBy {
+		if !measurement.ValidLabel(l) {
+			v.add(fmt.Sprintf("%s.GroupBy[%d]", path, j), "unknown source label %s", l)
+		}
+	}
+}
+
+// aggregations checks the aggregations of a metric of the given kind.
+// Counters only support their own aggregations, outlier policies only apply
+// to samples.
+func (v *validator) aggregations(path string, kind string, aggregations []string) {
+	if kind == modules.KindCounter {
+		for _, a := range aggregations {
+			if !isOneOf(a, modules.CounterAggregations) {
+				v.add(path, "unknown aggregation %s of a counter, expected one of %s", a, strings.Join(modules.CounterAggregations, ", "))
+			}
+		}
+		return
+	}
+
+	policies := 0
+	for _, a := range aggregations {
+		if isOneOf(a, modules.Aggregations) {
+			continue
+		}
+		_, ok, err := modules.ParseOutlierPolicy(a)
+		if !ok {
+			v.add(path, "unknown aggregation %s, expected one of %s or an outlier policy (%s, %s, %s)", a, strings.Join(modules.Aggregations, ", "),
+				modules.OutlierIQR, modules.OutlierMAD, modules.OutlierTrim)
+			continue
+		}
+		if err != nil {
+			v.add(path, "%v", err)
+		}
+		if kind == modules.KindGauge {
+			v.add(path, "outlier policy %s is not supported for gauges", a)
+		}
+		policies++
+	}
+	if policies > 1 {
+		v.add(path, "only one outlier policy per metric")
+	}
+}
+
+// derived checks the expressions of derived fields, also for cycles.
+func (v *validator) derived(path string, defs map[string]string) {
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	valid := true
+	for _, name := range names {
+		if _, err := expr.Parse(defs[name]); err != nil {
+			v.add(path+"."+name, "%v", err)
+			valid = false
+		}
+	}
+	if !valid {
+		return
+	}
+	if _, err := expr.Compile(defs); err != nil {
+		v.add(path, "%v", err)
+	}
+}
+
+// fields checks that names only refer to received fields.
+func (v *validator) fields(path string, names []string, fields map[string]bool) {
+	for j, f := range names {
+		if !fields[f] {
+			v.add(fmt.Sprintf("%s[%d]", path, j), "field %s is not in Fields or Derived", f)
+		}
+	}
+}
+
+// endpointRef checks that ref, "<endpoint>" or "<endpoint>/<metric>", names
+// a known endpoint if set.
+func (v *validator) endpointRef(path string, ref string, endpoints map[string]bool) {
+	if endpoint := strings.SplitN(ref, "/", 2)[0]; endpoint != "" && !endpoints[endpoint] {
+		v.add(path, "unknown endpoint %s", endpoint)
+	}
+}
+
+// metric checks a metric named "<endpoint>/<metric>".
+func (v *validator) metric(path string, metric string, endpoints map[string]bool) {
+	parts := strings.SplitN(metric, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		v.add(path, "expected <endpoint>/<metric>, got %q", metric)
+		return
+	}
+	if !endpoints[parts[0]] {
+		v.add(path, "unknown endpoint %s", parts[0])
+	}
+}
+
+func isOneOf(s string, list []string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
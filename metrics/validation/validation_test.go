@@ -0,0 +1,307 @@
+package validation
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sbaeurle/comb/metrics/config"
+)
+
+func TestValidate(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "script.tengo")
+	err := os.WriteFile(script, []byte("output := input"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generic := func() config.EndpointConfig {
+		return config.EndpointConfig{
+			Name:    "tracking",
+			Url:     "/tracking",
+			Module:  "GENERIC",
+			Fields:  []string{"frame-number", "processing-time", "source.node"},
+			Outputs: []string{"tracking.csv"},
+			Metrics: map[string][]string{"processing-time": {"MIN", "AVG"}},
+		}
+	}
+
+	type testCase struct {
+		cfg      func() config.Config
+		paths    []string
+		warnings []string
+	}
+	tests := map[string]testCase{
+		"valid": {
+			cfg: func() config.Config {
+				script := config.EndpointConfig{Name: "script", Url: "/script", Module: "SCRIPT", Config: map[string]string{"ScriptPath": script}}
+				return config.Config{Port: 8000, Endpoints: []config.EndpointConfig{generic(), script}}
+			},
+		},
+		"unknown module": {
+			cfg: func() config.Config {
+				e := generic()
+				e.Module = "GENERIK"
+				return config.Config{Endpoints: []config.EndpointConfig{e}}
+			},
+			paths: []string{"Endpoints[0].Module"},
+		},
+		"unknown output": {
+			cfg: func() config.Config {
+				e := generic()
+				e.Outputs = []string{"tracking.xlsx"}
+				return config.Config{Endpoints: []config.EndpointConfig{e}}
+			},
+			paths: []string{"Endpoints[0].Outputs[0]"},
+		},
+		"metrics": {
+			cfg: func() config.Config {
+				e := generic()
+				e.Metrics["latency"] = []string{"AVG"}
+				e.Metrics["processing-time"] = []string{"MEAN"}
+				return config.Config{Endpoints: []config.EndpointConfig{e}}
+			},
+			paths: []string{"Endpoints[0].Metrics.latency", "Endpoints[0].Metrics.processing-time"},
+		},
+		"outliers": {
+			cfg: func() config.Config {
+				e := generic()
+				e.Fields = append(e.Fields, "latency", "encoding-time", "objects")
+				e.Metrics["processing-time"] = []string{"AVG", "IQR"}
+				e.Metrics["latency"] = []string{"MAX", "MAD:3", "TRIM:5"}
+				e.Metrics["encoding-time"] = []string{"AVG", "TRIM"}
+				e.Metrics["objects"] = []string{"AVG", "TRIM:50", "IQR:x"}
+				return config.Config{Endpoints: []config.EndpointConfig{e}}
+			},
+			paths: []string{
+				"Endpoints[0].Metrics.encoding-time",
+				"Endpoints[0].Metrics.latency",
+				"Endpoints[0].Metrics.objects",
+				"Endpoints[0].Metrics.objects",
+				"Endpoints[0].Metrics.objects",
+			},
+		},
+		"fields": {
+			cfg: func() config.Config {
+				e := generic()
+				e.GroupBy = []string{"host"}
+				e.Timestamps = []string{"sent-time"}
+				e.TimestampUnit = "min"
+				e.Completeness.Severity = "ERROR"
+				return config.Config{Endpoints: []config.EndpointConfig{e}}
+			},
+			paths: []string{"Endpoints[0].GroupBy[0]", "Endpoints[0].Timestamps[0]", "Endpoints[0].TimestampUnit", "Endpoints[0].Completeness.Severity"},
+		},
+		"derived": {
+			cfg: func() config.Config {
+				e := generic()
+				e.Derived = map[string]string{"total-time": "processing-time +", "a": "b * 2", "b": "a / 2"}
+				e.Metrics["total-time"] = []string{"AVG"}
+				return config.Config{Endpoints: []config.EndpointConfig{e}}
+			},
+			paths: []string{"Endpoints[0].Derived.total-time"},
+		},
+		"derived fields": {
+			cfg: func() config.Config {
+				e := generic()
+				e.Derived = map[string]string{"fps": "1000 / processing-time"}
+				e.Dashboard = []string{"fps", "latency"}
+				e.Completeness.RequiredFields = []string{"fps"}
+				return config.Config{Endpoints: []config.EndpointConfig{e}}
+			},
+			paths: []string{"Endpoints[0].Dashboard[1]"},
+		},
+		"cyclic derived": {
+			cfg: func() config.Config {
+				e := generic()
+				e.Derived = map[string]string{"a": "b * 2", "b": "a / 2"}
+				return config.Config{Endpoints: []config.EndpointConfig{e}}
+			},
+			paths: []string{"Endpoints[0].Derived"},
+		},
+		"field types": {
+			cfg: func() config.Config {
+				e := generic()
+				e.Fields = append(e.Fields, "queue-length", "skipped-frames", "objects")
+				e.FieldTypes = map[string]config.FieldType{
+					"queue-length":   {Kind: "gauge", Unit: "frames"},
+					"skipped-frames": {Kind: "counter"},
+					"objects":        {Kind: "histogram"},
+				}
+				e.Metrics["queue-length"] = []string{"AVG", "IQR"}
+				e.Metrics["skipped-frames"] = []string{"TOTAL", "RATE", "AVG"}
+				return config.Config{Endpoints: []config.EndpointConfig{e}}
+			},
+			paths: []string{
+				"Endpoints[0].FieldTypes.objects.Kind",
+				"Endpoints[0].Metrics.queue-length",
+				"Endpoints[0].Metrics.skipped-frames",
+			},
+		},
+		"energy": {
+			cfg: func() config.Config {
+				power := config.EndpointConfig{Name: "power", Url: "/power", Module: "ENERGY", Config: map[string]string{"Frames": "tracking/frames-TOTAL"}}
+				unknown := config.EndpointConfig{Name: "gpu", Url: "/gpu", Module: "ENERGY", Config: map[string]string{"Frames": "detection"}}
+				self := config.EndpointConfig{Name: "cpu", Url: "/cpu", Module: "ENERGY", Config: map[string]string{"Frames": "cpu/"}}
+				return config.Config{Endpoints: []config.EndpointConfig{generic(), power, unknown, self}}
+			},
+			paths: []string{"Endpoints[3].Config.Frames", "Endpoints[2].Config.Frames"},
+		},
+		"chain": {
+			cfg: func() config.Config {
+				e := config.EndpointConfig{
+					Name:   "tracking",
+					Url:    "/tracking",
+					Module: "CHAIN",
+					Stages: []config.StageConfig{
+						{Name: "confident", Module: "FILTER", Config: map[string]string{"Where": "conf >= 0.5"}, Fields: []string{"conf"}},
+						{Name: "script", Module: "SCRIPT", Config: map[string]string{"ScriptPath": script}, Fields: []string{"processing-time"}},
+						{Module: "GENERIC", Fields: []string{"processing-time"}, Outputs: []string{"tracking.csv"}, Metrics: map[string][]string{"processing-time": {"AVG"}}},
+					},
+				}
+				return config.Config{Endpoints: []config.EndpointConfig{e}}
+			},
+		},
+		"invalid chain": {
+			cfg: func() config.Config {
+				e := config.EndpointConfig{
+					Name:    "tracking",
+					Url:     "/tracking",
+					Module:  "CHAIN",
+					Outputs: []string{"tracking.csv"},
+					Stages: []config.StageConfig{
+						{Name: "confident", Module: "FILTER", Fields: []string{"conf"}},
+						{Name: "confident", Module: "CHAIN"},
+						{Module: "GENERIC", Fields: []string{"processing-time"}, Outputs: []string{"tracking.txt"}, Metrics: map[string][]string{"processing-time": {"MEAN"}}},
+						{Module: "GENERIC", Fields: []string{"processing-time"}, Outputs: []string{"tracking.txt"}, Metrics: map[string][]string{"processing-time": {"AVG"}}},
+					},
+				}
+				return config.Config{Endpoints: []config.EndpointConfig{e}}
+			},
+			paths: []string{
+				"Endpoints[0].Outputs",
+				"Endpoints[0].Stages[0].Config.Where",
+				"Endpoints[0].Stages[1].Name",
+				"Endpoints[0].Stages[1].Module",
+				"Endpoints[0].Stages[3].Metrics.processing-time",
+				"Endpoints[0].Stages[3].Outputs[0]",
+				"Endpoints[0].Stages[2].Metrics.processing-time",
+			},
+		},
+		"relay": {
+			cfg: func() config.Config {
+				relay := config.RelayConfig{Upstream: "metrics:8000", BatchSize: -1, CAFile: "missing.crt", DrainTimeout: -time.Second}
+				return config.Config{Relay: relay, Endpoints: []config.EndpointConfig{generic()}}
+			},
+			paths: []string{"Relay.Upstream", "Relay.BatchSize", "Relay.DrainTimeout", "Relay.CAFile"},
+		},
+		"missing script": {
+			cfg: func() config.Config {
+				e := config.EndpointConfig{Name: "script", Url: "/script", Module: "SCRIPT"}
+				return config.Config{Endpoints: []config.EndpointConfig{e}}
+			},
+			paths: []string{"Endpoints[0].Config.ScriptPath"},
+		},
+		"mot": {
+			cfg: func() config.Config {
+				e := config.EndpointConfig{
+					Name:    "MOT",
+					Url:     "/mot",
+					Module:  "MOT",
+					Config:  map[string]string{"MotScript": script, "Benchmark": "MOT20", "SeqInfo": "MOT20-01", "GTFolder": "data"},
+					Outputs: []string{"MOT20-01.txt"},
+					Metrics: map[string][]string{"HOTA": {}},
+				}
+				return config.Config{Endpoints: []config.EndpointConfig{e}}
+			},
+			paths: []string{"Endpoints[0].Config.SplitToEval"},
+		},
+		"missing TrackEval": {
+			cfg: func() config.Config {
+				e := config.EndpointConfig{
+					Name:    "MOT",
+					Url:     "/mot",
+					Module:  "MOT",
+					Config:  map[string]string{"MotScript": "TrackEval/run_mot_challenge.py", "Benchmark": "MOT20", "SplitToEval": "train", "SeqInfo": "MOT20-01", "GTFolder": "data"},
+					Outputs: []string{"MOT20-01.txt"},
+				}
+				return config.Config{Endpoints: []config.EndpointConfig{e}}
+			},
+			paths:    []string{"Endpoints[0].Config.MotScript"},
+			warnings: []string{"Endpoints[0].Config.MotScript"},
+		},
+		"duplicate endpoints": {
+			cfg: func() config.Config {
+				return config.Config{Endpoints: []config.EndpointConfig{generic(), generic()}}
+			},
+			paths: []string{"Endpoints[1].Name", "Endpoints[1].Url"},
+		},
+		"general": {
+			cfg: func() config.Config {
+				return config.Config{
+					Port:    80000,
+					Layout:  config.LayoutConfig{Run: "{{.Run"},
+					TLS:     config.TLSConfig{CertFile: "server.crt"},
+					Summary: config.SummaryConfig{SortBy: "detection/processing-time-AVG"},
+					Compare: config.CompareConfig{Alpha: 5, Regressions: []config.RegressionConfig{{Metric: "tracking"}}},
+				}
+			},
+			paths: []string{"Port", "Layout.Run", "TLS", "TLS.CertFile", "Summary.SortBy", "Compare.Alpha", "Compare.Regressions[0].Metric"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var paths, warnings []string
+			for _, p := range Validate(tc.cfg()) {
+				paths = append(paths, p.Path)
+				if p.Warning {
+					warnings = append(warnings, p.Path)
+				}
+			}
+			if !reflect.DeepEqual(tc.paths, paths) {
+				t.Fatalf("expected: %v, got: %v", tc.paths, paths)
+			}
+			if !reflect.DeepEqual(tc.warnings, warnings) {
+				t.Fatalf("expected: %v, got: %v", tc.warnings, warnings)
+			}
+		})
+	}
+}
+
+func TestValidateRelay(t *testing.T) {
+	type testCase struct {
+		cfg   config.Config
+		paths []string
+	}
+	tests := map[string]testCase{
+		"valid": {
+			cfg: config.Config{
+				Port:      8000,
+				Relay:     config.RelayConfig{Upstream: "https://metrics:8000"},
+				Endpoints: []config.EndpointConfig{{Url: "/tracking"}, {Url: "/detection"}},
+			},
+		},
+		"invalid": {
+			cfg: config.Config{
+				Relay:     config.RelayConfig{Interval: -1},
+				Endpoints: []config.EndpointConfig{{Url: "tracking"}, {Url: "/detection"}, {Url: "/detection"}},
+			},
+			paths: []string{"Relay.Upstream", "Relay.Interval", "Endpoints[0].Url", "Endpoints[2].Url"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var paths []string
+			for _, p := range ValidateRelay(tc.cfg) {
+				paths = append(paths, p.Path)
+			}
+			if !reflect.DeepEqual(tc.paths, paths) {
+				t.Fatalf("expected: %v, got: %v", tc.paths, paths)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package version
+
+import "runtime/debug"
+
+// Version is set at build time, e.g. go build -ldflags "-X github.com/sbaeurle/comb/metrics/version.Version=v1.0.0".
+var Version string
+
+// String returns the version of the running binary. Without a version set at
+// build time the module version recorded by the go tool is used.
+func String() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "unknown"
+}
@@ -1,20 +1,28 @@
 package cmd
 
 import (
+	"bytes"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/sbaeurle/comb/lib/configfile"
 	"github.com/sbaeurle/comb/orchestration/config"
+	"github.com/sbaeurle/comb/orchestration/version"
 	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "orchestration",
-	Short: "Lightweight suite to schedule benchmark on configured devices.",
+	Use:     "orchestration",
+	Short:   "Lightweight suite to schedule benchmark on configured devices.",
+	Version: version.String(),
 }
 
 var (
 	backend     string
 	cfgFile     string
+	profile     string
+	overlays    []string
 	development bool
 	log         config.Logger
 	cfg         config.Config
@@ -31,10 +39,13 @@ func init() {
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(matchingCmd)
+	rootCmd.AddCommand(configCmd)
 
 	// Add configuration options
 	rootCmd.PersistentFlags().StringVar(&backend, "backend", "ssh", "backend used to schedule the workload. Available: (ssh, k8s, edge-io)")
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "config.yaml", "config file")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "profile of the config file to apply")
+	rootCmd.PersistentFlags().StringArrayVar(&overlays, "overlay", nil, "config file merged onto the configuration (repeatable)")
 	rootCmd.PersistentFlags().BoolVar(&development, "development", false, "development mode")
 }
 
@@ -43,7 +54,7 @@ func initConfig() {
 	viper.AutomaticEnv()
 	// Check for configuration errors.
 	// Exit application if errors are present.
-	err := viper.ReadInConfig()
+	err := readConfig()
 	cobra.CheckErr(err)
 
 	viper.Unmarshal(&cfg)
@@ -59,3 +70,17 @@ func initConfig() {
 	defer logger.Sync()
 	log = logger.Sugar()
 }
+
+// readConfig loads the config file with its includes, profile and overlays.
+func readConfig() error {
+	doc, err := configfile.Load(cfgFile, profile, overlays)
+	if err != nil {
+		return err
+	}
+	tmp, err := yaml.Marshal(doc.Settings)
+	if err != nil {
+		return err
+	}
+	viper.SetConfigType("yaml")
+	return viper.ReadConfig(bytes.NewReader(tmp))
+}
@@ -2,9 +2,10 @@ package cmd
 
 import (
 	"errors"
-	"net/http"
 
 	"github.com/spf13/cobra"
+	"github.com/sbaeurle/comb/lib/client"
+	"github.com/sbaeurle/comb/orchestration/evaluation"
 	"github.com/sbaeurle/comb/orchestration/executor"
 	"github.com/sbaeurle/comb/orchestration/executor/ssh"
 	"github.com/sbaeurle/comb/orchestration/matching"
@@ -16,11 +17,18 @@ var runCmd = &cobra.Command{
 	RunE:  benchmark,
 }
 
+var resume string
+var name string
+
+func init() {
+	runCmd.Flags().StringVar(&resume, "resume", "", "resume the benchmark session with this ID or in this result folder")
+	runCmd.Flags().StringVar(&name, "name", "", "name of the benchmark used in the result folder layout")
+}
+
 func benchmark(cmd *cobra.Command, args []string) error {
 	switch backend {
 	case "ssh":
-		var exec executor.Executor
-		exec, err := ssh.NewSSHExecutor(log, &cfg)
+		eval, err := evaluation.NewClient(&cfg)
 		if err != nil {
 			return err
 		}
@@ -28,15 +36,34 @@ func benchmark(cmd *cobra.Command, args []string) error {
 		matchings := matching.GenerateSchedules(cfg)
 		log.Infof("Generated Matchings: %v", matchings)
 
-		_, err = http.Post(cfg.Evaluation+"/start-benchmark", "application/json", nil)
+		var session *client.Client
+		var info client.SessionInfo
+		if resume != "" {
+			session, info, err = eval.ResumeBenchmark(resume)
+			if err != nil {
+				return err
+			}
+			log.Infof("Resumed benchmark session %s writing to %s after %d completed runs", info.ID, info.Root, len(info.Completed()))
+		} else {
+			session, info, err = eval.StartBenchmark(name)
+			if err != nil {
+				return err
+			}
+			log.Infof("Started benchmark session %s writing to %s", info.ID, info.Root)
+		}
+
+		var exec executor.Executor
+		exec, err = ssh.NewSSHExecutor(log, &cfg, session)
 		if err != nil {
 			return err
 		}
+		exec.Resume(info.Completed())
+
 		for _, matching := range matchings {
 			err := exec.RunMatching(matching)
 			cobra.CheckErr(err)
 		}
-		_, err = http.Post(cfg.Evaluation+"/end-benchmark", "application/json", nil)
+		err = session.EndBenchmark()
 		if err != nil {
 			return err
 		}
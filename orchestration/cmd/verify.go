@@ -18,7 +18,7 @@ func verify(cmd *cobra.Command, args []string) error {
 	switch backend {
 	case "ssh":
 		var exec executor.Executor
-		exec, err := ssh.NewSSHExecutor(log, &cfg)
+		exec, err := ssh.NewSSHExecutor(log, &cfg, nil)
 		cobra.CheckErr(err)
 		errs := exec.VerifyEnvironment()
 		if len(errs) != 0 {
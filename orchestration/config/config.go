@@ -16,10 +16,38 @@ type Logger interface {
 }
 
 type Config struct {
-	SSH        *SSHConfig
-	Workload   []WorkloadConfig
-	NodeGroups []NodeGroup
-	Evaluation string
+	SSH             *SSHConfig
+	Workload        []WorkloadConfig
+	NodeGroups      []NodeGroup
+	Evaluation      string
+	EvaluationToken string
+	// IngestToken is passed to the workloads in EVALUATION_TOKEN to report
+	// their measurements.
+	IngestToken   string
+	EvaluationTLS *TLSConfig
+	// Retries repeats runs which were aborted or whose data the metric
+	// collection service considered incomplete.
+	Retries int
+}
+
+// Redacted returns a copy of the configuration without secrets.
+func (c Config) Redacted() Config {
+	if c.EvaluationToken != "" {
+		c.EvaluationToken = "<redacted>"
+	}
+	if c.IngestToken != "" {
+		c.IngestToken = "<redacted>"
+	}
+	return c
+}
+
+// TLSConfig configures the HTTPS connection to the metric collection service.
+// CertFile and KeyFile provide a client certificate for mutual TLS.
+type TLSConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
 }
 
 type SSHConfig struct {
@@ -0,0 +1,55 @@
+package evaluation
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/sbaeurle/comb/lib/client"
+	"github.com/sbaeurle/comb/orchestration/config"
+)
+
+// NewClient creates a client of the metric collection service configured by
+// cfg. Requests which did not reach the service are retried.
+func NewClient(cfg *config.Config) (*client.Client, error) {
+	opts := client.Options{Token: cfg.EvaluationToken, Retries: 3}
+	if cfg.EvaluationTLS != nil {
+		tlsCfg, err := newTLSConfig(cfg.EvaluationTLS)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLS = tlsCfg
+	}
+	return client.New(cfg.Evaluation, opts)
+}
+
+func newTLSConfig(c *config.TLSConfig) (*tls.Config, error) {
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: c.InsecureSkipVerify}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, errors.New("client certificate requires both CertFile and KeyFile")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
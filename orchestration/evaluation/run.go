@@ -0,0 +1,35 @@
+package evaluation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/sbaeurle/comb/orchestration/config"
+)
+
+// Manifest is the part of the run manifest contributed by the orchestrator.
+type Manifest struct {
+	Version   string                      `json:"version"`
+	Config    config.Config               `json:"config"`
+	Workloads map[string]WorkloadManifest `json:"workloads"`
+}
+
+// WorkloadManifest describes how a workload was deployed in a run.
+type WorkloadManifest struct {
+	Node    string            `json:"node"`
+	Tag     string            `json:"tag"`
+	Image   string            `json:"image"`
+	Digest  string            `json:"digest,omitempty"`
+	Command string            `json:"command"`
+	Facts   map[string]string `json:"facts,omitempty"`
+}
+
+// NewSourceToken generates a random token identifying a single workload of a run.
+func NewSourceToken() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
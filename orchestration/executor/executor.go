@@ -3,4 +3,6 @@ package executor
 type Executor interface {
 	VerifyEnvironment() []error
 	RunMatching(workloads map[string]string) error
+	// Resume skips all runs with one of the given matchings, as they were completed before.
+	Resume(completed []map[string]string)
 }
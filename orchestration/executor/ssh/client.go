@@ -71,7 +71,7 @@ func (s *sshClient) executeCommand(command string) ([]byte, error) {
 	return session.CombinedOutput(command)
 }
 
-func (s *sshClient) issueCommand(done chan struct{}, command string) error {
+func (s *sshClient) issueCommand(command string) error {
 	session, err := s.client.NewSession()
 	if err != nil {
 		return err
@@ -87,7 +87,6 @@ func (s *sshClient) issueCommand(done chan struct{}, command string) error {
 	go logOutput(s.log, stopRead, r, s.client.RemoteAddr().String())
 
 	err = session.Run(command)
-	done <- struct{}{}
 	close(stopRead)
 	return err
 }
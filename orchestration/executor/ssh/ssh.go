@@ -1,28 +1,34 @@
 package ssh
 
 import (
-	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"os"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"text/template"
 	"time"
 
+	"github.com/sbaeurle/comb/lib/client"
 	"github.com/sbaeurle/comb/orchestration/config"
+	"github.com/sbaeurle/comb/orchestration/evaluation"
+	"github.com/sbaeurle/comb/orchestration/version"
 )
 
 // Executor to schedule benchmark runs using SSH and Docker.
 type SSHExecutor struct {
 	log       config.Logger
 	cfg       *config.Config
+	eval      *client.Client
 	templates map[string]*template.Template
+	completed []map[string]string
 }
 
-func NewSSHExecutor(log config.Logger, cfg *config.Config) (*SSHExecutor, error) {
+// NewSSHExecutor creates an executor reporting to the metric service through eval.
+// eval may be nil if no benchmark is run.
+func NewSSHExecutor(log config.Logger, cfg *config.Config, eval *client.Client) (*SSHExecutor, error) {
 	templates := make(map[string]*template.Template)
 	for tag, command := range cfg.SSH.Commands {
 		tmp, err := template.New(tag).Parse(command)
@@ -30,11 +36,15 @@ func NewSSHExecutor(log config.Logger, cfg *config.Config) (*SSHExecutor, error)
 			return nil, err
 		}
 		templates[tag] = tmp
+		if !strings.Contains(command, ".Env") {
+			log.Warnf("Command %s does not pass {{.Env}}, workloads do not receive their evaluation tokens", tag)
+		}
 	}
 
 	return &SSHExecutor{
 		log:       log,
 		cfg:       cfg,
+		eval:      eval,
 		templates: templates,
 	}, nil
 }
@@ -82,6 +92,19 @@ func (s *SSHExecutor) VerifyEnvironment() []error {
 	return errors
 }
 
+func (s *SSHExecutor) Resume(completed []map[string]string) {
+	s.completed = completed
+}
+
+func (s *SSHExecutor) isCompleted(matching map[string]string) bool {
+	for _, c := range s.completed {
+		if reflect.DeepEqual(c, matching) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *SSHExecutor) RunMatching(matching map[string]string) error {
 	nodeCache := make(map[string]*config.NodeGroup)
 	for i, node := range s.cfg.NodeGroups {
@@ -107,10 +130,24 @@ func (s *SSHExecutor) RunMatching(matching map[string]string) error {
 			nodes = append(nodes, n[v%len(n)])
 			tmp[workload.Name] = fmt.Sprintf("%s-%s-%s", matching[workload.Name], n[v%len(n)], p[i%len(p)])
 		}
-		s.log.Infof("Start Benchmark Run: %v", tmp)
-		err := s.singleRun(s.cfg.Workload, tmp, nodes, tags)
-		if err != nil {
-			return err
+		if s.isCompleted(tmp) {
+			s.log.Infof("Skip completed Benchmark Run: %v", tmp)
+			continue
+		}
+		for attempt := 0; ; attempt++ {
+			s.log.Infof("Start Benchmark Run: %v", tmp)
+			err := s.singleRun(s.cfg.Workload, tmp, nodes, tags)
+			if !errors.Is(err, errRunFailed) {
+				if err != nil {
+					return err
+				}
+				break
+			}
+			if attempt >= s.cfg.Retries {
+				s.log.Errorf("Benchmark Run %v failed after %d attempts", tmp, attempt+1)
+				break
+			}
+			s.log.Warnf("Retry failed Benchmark Run %v", tmp)
 		}
 	}
 
@@ -119,33 +156,61 @@ func (s *SSHExecutor) RunMatching(matching map[string]string) error {
 
 func (s *SSHExecutor) singleRun(workloads []config.WorkloadConfig, matching map[string]string, nodes []string, tags []string) error {
 	var networkAddresses = make(map[string]string)
-	done := make(chan struct{})
+	exits := make(chan workloadExit, len(workloads))
 
 	if len(workloads) != len(nodes) && len(workloads) != len(tags) {
 		return errors.New("slices need to be of equal length")
 	}
-	tmp, err := json.Marshal(matching)
-	if err != nil {
-		return err
-	}
 
-	resp, err := http.Post(s.cfg.Evaluation+"/start-run", "application/json", bytes.NewBuffer(tmp))
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return err
+	// Issue a token per workload to attribute measurements to their source.
+	tokens := make([]string, len(workloads))
+	req := client.RunRequest{Matching: matching, Sources: make(map[string]client.Source)}
+	for i, workload := range workloads {
+		token, err := evaluation.NewSourceToken()
+		if err != nil {
+			return err
+		}
+		tokens[i] = token
+		req.Sources[token] = client.Source{Node: nodes[i], Workload: workload.Name, Container: workload.Name}
 	}
 
-	networkAddresses["Evaluation"] = s.cfg.Evaluation
+	// Prepare all workloads before the run starts, so pulling images is not part of the measurements.
+	manifest := evaluation.Manifest{
+		Version:   version.String(),
+		Config:    s.cfg.Redacted(),
+		Workloads: make(map[string]evaluation.WorkloadManifest),
+	}
+	commands := make([]string, len(workloads))
+	conns := make([]*sshClient, len(workloads))
+	networkAddresses["Evaluation"] = s.eval.URL()
 	for i, workload := range workloads {
 		node := nodes[i]
 		tag := tags[i]
 		networkAddresses[workload.Name] = node
+		networkAddresses["SourceToken"] = tokens[i]
+
+		// The manifest records the command without the ingest token.
+		env := map[string]string{"EVALUATION_SOURCE_TOKEN": tokens[i]}
+		if s.cfg.IngestToken != "" {
+			env["EVALUATION_TOKEN"] = "<redacted>"
+		}
+		networkAddresses["Env"] = envFlags(env)
+		recorded, err := createCommand(s.templates, networkAddresses, workload, tag)
+		if err != nil {
+			return err
+		}
+		s.log.Debugf("Parsed Command %s: %s", workload.Name, recorded)
 
 		// Build shell tmp to be executed on node
+		if s.cfg.IngestToken != "" {
+			env["EVALUATION_TOKEN"] = s.cfg.IngestToken
+		}
+		networkAddresses["Env"] = envFlags(env)
 		command, err := createCommand(s.templates, networkAddresses, workload, tag)
 		if err != nil {
 			return err
 		}
-		s.log.Debugf("Parsed Command %s: %s", workload.Name, command)
+		commands[i] = command
 
 		// Create ssh connection to node for workload
 		conn, err := newSSHClient(s.log, s.cfg.SSH.KeyFile, s.cfg.SSH.User, node)
@@ -156,6 +221,7 @@ func (s *SSHExecutor) singleRun(workloads []config.WorkloadConfig, matching map[
 			conn.executeCommand(fmt.Sprintf("docker rm -f %s", name))
 			conn.close()
 		}(workload.Name)
+		conns[i] = conn
 
 		// Prepare environment for benchmark
 		err = prepareEnvironment(conn, workload.Image, tag, workload.LocalData)
@@ -163,39 +229,78 @@ func (s *SSHExecutor) singleRun(workloads []config.WorkloadConfig, matching map[
 			s.log.Errorf("%s:%s: %s", workload.Image, tag, err)
 			return err
 		}
-		s.log.Infof("Schedule %s on %s", workload.Name, node)
 
-		go func(name string) {
-			err := conn.issueCommand(done, command)
+		manifest.Workloads[workload.Name] = evaluation.WorkloadManifest{
+			Node:    node,
+			Tag:     tag,
+			Image:   fmt.Sprintf("%s:%s", workload.Image, tag),
+			Digest:  imageDigest(conn, workload.Image, tag),
+			Command: recorded,
+			Facts:   nodeFacts(conn),
+		}
+	}
+	req.Manifest = &manifest
+
+	err := s.eval.StartRun(req)
+	if err != nil {
+		return err
+	}
+
+	for i, workload := range workloads {
+		s.log.Infof("Schedule %s on %s", workload.Name, nodes[i])
+
+		go func(conn *sshClient, name string, command string) {
+			err := conn.issueCommand(command)
 			if err != nil {
 				s.log.Errorf("%s, %s", name, err)
 			}
-		}(workload.Name)
+			exits <- workloadExit{name: name, err: err}
+		}(conns[i], workload.Name, commands[i])
 
 		time.Sleep(10 * time.Second) // Sleep a second to help every container to startup correctly
 	}
 
-	<-done
-	resp, err = http.Post(s.cfg.Evaluation+"/end-run", "application/json", nil)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		return err
+	// The run ends with the first workload. If it failed, the partial
+	// measurements are discarded and the run is retried on resume.
+	exit := <-exits
+	if exit.err != nil {
+		err := s.eval.AbortRun(fmt.Sprintf("%s failed: %v", exit.name, exit.err))
+		if err != nil {
+			return err
+		}
+		s.log.Warnf("Aborted Benchmark Run %v: %s failed", matching, exit.name)
+		return errRunFailed
 	}
 
-	results := make(map[string]interface{})
-	err = json.NewDecoder(resp.Body).Decode(&results)
+	res, err := s.eval.EndRun()
 	if err != nil {
 		return err
 	}
-	s.log.Infof("%v", results)
+	s.log.Infof("%v", res.Results)
+	if res.Status == client.StatusFailed {
+		s.log.Warnf("Incomplete data in Benchmark Run %v: %v", matching, res.Endpoints)
+		return errRunFailed
+	}
 	return nil
 }
 
+// errRunFailed marks runs which should be repeated.
+var errRunFailed = errors.New("benchmark run failed")
+
+// workloadExit reports how the command of a workload ended.
+type workloadExit struct {
+	name string
+	err  error
+}
+
 func createCommand(cache map[string]*template.Template, mapping map[string]string, workload config.WorkloadConfig, tag string) (string, error) {
 	var tmp strings.Builder
+	// Env differs per run, it is filled in from the mapping.
 	wl := struct {
 		config.WorkloadConfig
 		Tag string
-	}{workload, tag}
+		Env string
+	}{workload, tag, "{{.Env}}"}
 	err := cache[tag].Execute(&tmp, wl)
 	if err != nil {
 		return "", err
@@ -213,6 +318,21 @@ func createCommand(cache map[string]*template.Template, mapping map[string]strin
 	return command.String(), nil
 }
 
+// envFlags passes env to the docker container, quoted for the shell.
+func envFlags(env map[string]string) string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	flags := make([]string, len(keys))
+	for i, k := range keys {
+		flags[i] = fmt.Sprintf("-e '%s=%s'", k, strings.ReplaceAll(env[k], "'", `'\''`))
+	}
+	return strings.Join(flags, " ")
+}
+
 func prepareEnvironment(connection *sshClient, image string, tag string, files []string) error {
 	// Preload Image & ensure most recent image version TODO: Log console output
 	_, err := connection.executeCommand(fmt.Sprintf("docker pull %s:%s", image, tag))
@@ -238,6 +358,36 @@ func prepareEnvironment(connection *sshClient, image string, tag string, files [
 	return nil
 }
 
+// nodeFactCommands are run on every node to describe its hardware and software in the run manifest.
+var nodeFactCommands = map[string]string{
+	"kernel": "uname -srm",
+	"cpus":   "nproc",
+	"memory": "awk '/MemTotal/ {print $2 \" \" $3}' /proc/meminfo",
+	"model":  "tr -d '\\0' < /proc/device-tree/model",
+	"docker": "docker version --format '{{.Server.Version}}'",
+}
+
+func nodeFacts(connection *sshClient) map[string]string {
+	facts := make(map[string]string)
+	for name, command := range nodeFactCommands {
+		out, err := connection.executeCommand(command)
+		if err != nil {
+			continue
+		}
+		facts[name] = strings.TrimSpace(string(out))
+	}
+	return facts
+}
+
+// imageDigest returns the repository digest of the pulled image, empty if it is unknown.
+func imageDigest(connection *sshClient, image string, tag string) string {
+	out, err := connection.executeCommand(fmt.Sprintf("docker image inspect --format '{{index .RepoDigests 0}}' %s:%s", image, tag))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
 func generateRuns(matching map[string]string, nodeCache map[string]*config.NodeGroup, workloadCache map[string]*config.WorkloadConfig) (map[string][]string, int) {
 	possible := make(map[string][]string, len(matching))
 	var runs int
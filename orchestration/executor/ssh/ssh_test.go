@@ -3,6 +3,7 @@ package ssh
 import (
 	"reflect"
 	"testing"
+	"text/template"
 
 	"github.com/sbaeurle/comb/orchestration/config"
 )
@@ -76,3 +77,38 @@ func TestGenerateOptions(t *testing.T) {
 
 	}
 }
+
+func TestCreateCommand(t *testing.T) {
+	type testCase struct {
+		command  string
+		env      map[string]string
+		expected string
+	}
+	tests := map[string]testCase{
+		"env": {
+			command:  "docker run --name {{.Name}} {{.Env}} {{.Image}}:{{.Tag}} {{.Command}}",
+			env:      map[string]string{"EVALUATION_TOKEN": "token", "EVALUATION_SOURCE_TOKEN": "source"},
+			expected: "docker run --name Tracking -e 'EVALUATION_SOURCE_TOKEN=source' -e 'EVALUATION_TOKEN=token' tracking:cpu track --evaluation-address http://metrics",
+		},
+		"quoted": {
+			command:  "docker run {{.Env}} {{.Image}}:{{.Tag}}",
+			env:      map[string]string{"EVALUATION_TOKEN": "it's"},
+			expected: `docker run -e 'EVALUATION_TOKEN=it'\''s' tracking:cpu`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			templates := map[string]*template.Template{"cpu": template.Must(template.New("cpu").Parse(tc.command))}
+			mapping := map[string]string{"Evaluation": "http://metrics", "Env": envFlags(tc.env)}
+			workload := config.WorkloadConfig{Name: "Tracking", Image: "tracking", Command: "track --evaluation-address {{.Evaluation}}"}
+			command, err := createCommand(templates, mapping, workload, "cpu")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if command != tc.expected {
+				t.Fatalf("expected: %v, got: %v", tc.expected, command)
+			}
+		})
+	}
+}
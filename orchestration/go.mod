@@ -4,9 +4,15 @@ go 1.16
 
 require (
 	github.com/bramvdbogaerde/go-scp v1.1.0
+	github.com/sbaeurle/comb/lib v0.1.0
 	github.com/spf13/cobra v1.2.1
 	github.com/spf13/viper v1.9.0
 	go.uber.org/zap v1.19.1
 	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
+	gopkg.in/yaml.v2 v2.4.0
 	k8s.io/client-go v0.22.4 // indirect
 )
+
+// The lib module is developed in this repository, builds use its checkout until
+// lib/v0.1.0 is tagged.
+replace github.com/sbaeurle/comb/lib => ../lib